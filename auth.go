@@ -0,0 +1,191 @@
+package xhttp
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+func (r *request) BasicAuth(username string, password string) Request {
+	r.req.SetBasicAuth(username, password)
+	return r
+}
+
+func (r *request) Bearer(token string) Request {
+	r.req.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+// BasicAuth authorizes requests that do not carry an Authorization header yet.
+func (c Client) BasicAuth(username string, password string) Client {
+	return c.RequestInterceptor(func(req Request) error {
+		if len(req.Request().Header.Get("Authorization")) == 0 {
+			req.BasicAuth(username, password)
+		}
+		return nil
+	})
+}
+
+// BearerToken authorizes requests that do not carry an Authorization header yet.
+func (c Client) BearerToken(token string) Client {
+	return c.RequestInterceptor(func(req Request) error {
+		if len(req.Request().Header.Get("Authorization")) == 0 {
+			req.Bearer(token)
+		}
+		return nil
+	})
+}
+
+func (c Client) DigestAuth(username string, password string) Client {
+	return c.Interceptor(DigestAuthInterceptor(username, password))
+}
+
+// DigestAuthInterceptor answers the Digest challenge of a 401 response,
+// RFC 7616, and replays the request. Challenges are kept per host so later
+// requests are authorized up front with an increasing nonce count.
+func DigestAuthInterceptor(username string, password string) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	var mu sync.Mutex
+	challenges := make(map[string]*digestChallenge)
+	return func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			r := req.Request()
+			mu.Lock()
+			c := challenges[r.URL.Host]
+			mu.Unlock()
+			if c != nil {
+				r.Header.Set("Authorization", c.authorize(r.Method, r.URL.RequestURI(), username, password, ""))
+			}
+			resp, err := next(req)
+			if err != nil || resp.Response().StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+			res := resp.Response()
+			nc := parseDigestChallenge(res.Header.Values("WWW-Authenticate"))
+			if nc == nil || (c != nil && !nc.stale && nc.nonce == c.nonce) {
+				return resp, nil
+			}
+			if err := rewindRequestBody(r); err != nil {
+				return resp, nil
+			}
+			res.Body.Close()
+			mu.Lock()
+			challenges[r.URL.Host] = nc
+			mu.Unlock()
+			r.Header.Set("Authorization", nc.authorize(r.Method, r.URL.RequestURI(), username, password, ""))
+			return next(req)
+		}
+	}
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	stale     bool
+	userhash  bool
+
+	mu sync.Mutex
+	nc uint32
+}
+
+func parseDigestChallenge(values []string) *digestChallenge {
+	for _, v := range values {
+		params, ok := parseAuthorization(v, "Digest")
+		if !ok {
+			continue
+		}
+		c := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			stale:     strings.EqualFold(params["stale"], "true"),
+			userhash:  strings.EqualFold(params["userhash"], "true"),
+		}
+		if len(c.algorithm) == 0 {
+			c.algorithm = "MD5"
+		}
+		if c.hash() == nil {
+			continue
+		}
+		for _, q := range strings.Split(params["qop"], ",") {
+			if strings.TrimSpace(q) == "auth" {
+				c.qop = "auth"
+			}
+		}
+		if len(params["qop"]) > 0 && len(c.qop) == 0 {
+			// only auth-int is offered, which needs the whole body
+			continue
+		}
+		return c
+	}
+	return nil
+}
+
+func (c *digestChallenge) hash() func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(c.algorithm), "-sess")) {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+func (c *digestChallenge) h(s string) string {
+	h := c.hash()()
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// authorize returns the Authorization header, a random cnonce is used when cnonce is empty.
+func (c *digestChallenge) authorize(method string, uri string, username string, password string, cnonce string) string {
+	if len(cnonce) == 0 {
+		var b [8]byte
+		rand.Read(b[:])
+		cnonce = hex.EncodeToString(b[:])
+	}
+	c.mu.Lock()
+	c.nc++
+	nc := fmt.Sprintf("%08x", c.nc)
+	c.mu.Unlock()
+
+	ha1 := c.h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = c.h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := c.h(method + ":" + uri)
+	var response string
+	if len(c.qop) > 0 {
+		response = c.h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	} else {
+		response = c.h(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	user := username
+	if c.userhash {
+		user = c.h(username + ":" + c.realm)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username="%s", realm="%s", nonce="%s", uri="%s"`,
+		quoteEscaper.Replace(user), quoteEscaper.Replace(c.realm), c.nonce, uri)
+	if len(c.qop) > 0 {
+		fmt.Fprintf(&b, `, qop=%s, nc=%s, cnonce="%s"`, c.qop, nc, cnonce)
+	}
+	fmt.Fprintf(&b, `, response="%s", algorithm=%s`, response, c.algorithm)
+	if len(c.opaque) > 0 {
+		fmt.Fprintf(&b, `, opaque="%s"`, c.opaque)
+	}
+	if c.userhash {
+		b.WriteString(", userhash=true")
+	}
+	return b.String()
+}
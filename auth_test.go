@@ -0,0 +1,80 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBasicAndBearerAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	c := NewClient().BasicAuth("user", "pass")
+	if s, _ := c.Get(context.TODO(), srv.URL).Do().String(); s != "Basic dXNlcjpwYXNz" {
+		t.Fatal("unexpected authorization", s)
+	}
+	if s, _ := c.Get(context.TODO(), srv.URL).Bearer("t").Do().String(); s != "Bearer t" {
+		t.Fatal("request auth should win, got", s)
+	}
+	if s, _ := NewClient().BearerToken("abc").Get(context.TODO(), srv.URL).Do().String(); s != "Bearer abc" {
+		t.Fatal("unexpected authorization", s)
+	}
+}
+
+func TestDigestAuthorize(t *testing.T) {
+	// RFC 2617 section 3.5
+	c := parseDigestChallenge([]string{`Digest realm="testrealm@host.com", qop="auth,auth-int", ` +
+		`nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`})
+	if c == nil {
+		t.Fatal("challenge not parsed")
+	}
+	s := c.authorize("GET", "/dir/index.html", "Mufasa", "Circle Of Life", "0a4f113b")
+	if !strings.Contains(s, `response="6629fae49393a05397450978507c4ef1"`) || !strings.Contains(s, "nc=00000001") {
+		t.Fatal("unexpected authorization", s)
+	}
+}
+
+func TestDigestAuthInterceptor(t *testing.T) {
+	var challenges int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params, ok := parseAuthorization(r.Header.Get("Authorization"), "Digest")
+		c := &digestChallenge{realm: "dev", nonce: "n1", algorithm: "SHA-256", qop: "auth"}
+		if ok {
+			nc, _ := strconv.ParseUint(params["nc"], 16, 32)
+			c.nc = uint32(nc) - 1
+			want, _ := parseAuthorization(c.authorize(r.Method, r.URL.RequestURI(), "admin", "pw", params["cnonce"]), "Digest")
+			if params["response"] == want["response"] {
+				b, _ := _ReadAll(r.Body)
+				w.Write([]byte(params["nc"] + " " + string(b)))
+				return
+			}
+		}
+		atomic.AddInt32(&challenges, 1)
+		w.Header().Set("WWW-Authenticate", `Digest realm="dev", nonce="n1", qop="auth", algorithm=SHA-256`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	c := NewClient().DigestAuth("admin", "pw")
+	if s, err := c.Post(context.TODO(), srv.URL+"/a?b=1", "body").Do().String(); err != nil || s != "00000001 body" {
+		t.Fatal(s, err)
+	}
+	if s, err := c.Get(context.TODO(), srv.URL+"/c").Do().String(); err != nil || s != "00000002 " {
+		t.Fatal(s, err)
+	}
+	if n := atomic.LoadInt32(&challenges); n != 1 {
+		t.Fatal("want 1 challenge, got", n)
+	}
+
+	resp := NewClient().DigestAuth("admin", "wrong").Get(context.TODO(), srv.URL).Do()
+	if code := resp.Response().StatusCode; code != http.StatusUnauthorized {
+		t.Fatal("want 401, got", code)
+	}
+}
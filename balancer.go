@@ -0,0 +1,262 @@
+package xhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNoBackend = errors.New("no backend available")
+
+type BalanceStrategy int
+
+const (
+	RoundRobin BalanceStrategy = iota
+	LeastOutstanding
+	// Weighted is a smooth weighted round robin on Backend.Weight.
+	Weighted
+)
+
+type Backend struct {
+	URL string
+
+	// Optional. Default value 1.
+	Weight int
+}
+
+type BalancerConfig struct {
+	Backends []Backend
+	Strategy BalanceStrategy
+
+	// MaxFailures consecutive failures eject a backend. Optional. Default value 3.
+	MaxFailures int
+
+	// Cooldown before an ejected backend is admitted again. Optional. Default value 30 seconds.
+	Cooldown time.Duration
+
+	// IsFailure reports failed calls. It sees errors only when the request
+	// reached the transport and the caller's context is not done, res is
+	// set whenever there is a response. Optional. Default value connection
+	// errors and 5xx responses.
+	IsFailure func(res *http.Response, err error) bool
+
+	Now func() time.Time
+}
+
+// BaseURLs balances requests with a relative url over urls round robin.
+func (c Client) BaseURLs(urls ...string) Client {
+	backends := make([]Backend, len(urls))
+	for i, u := range urls {
+		backends[i] = Backend{URL: u}
+	}
+	return c.Balancer(BalancerConfig{Backends: backends})
+}
+
+// Balancer resolves requests with a relative url to one of the backends
+// before any interceptor runs, so interceptors see the backend host.
+func (c Client) Balancer(config BalancerConfig) Client {
+	c.baseURL = ""
+	c.balancer = newBalancer(config)
+	return c
+}
+
+func defaultIsFailure(res *http.Response, err error) bool {
+	if res != nil {
+		// errors of interceptors such as ExpectStatus are not the backend's
+		return res.StatusCode >= 500
+	}
+	return err != nil
+}
+
+type backend struct {
+	url    *url.URL
+	weight int
+
+	current      int
+	outstanding  int
+	failures     int
+	ejectedUntil time.Time
+}
+
+type balancer struct {
+	config BalancerConfig
+	err    error
+
+	mu       sync.Mutex
+	backends []*backend
+	next     int
+}
+
+// BalancerInterceptor sends requests whose url has no host to one of the
+// backends, prefixing the backend path. Backends failing MaxFailures times
+// in a row are ejected for Cooldown, if all are ejected the one coming back
+// first is used.
+//
+// Interceptors added before it see the relative url, Client.Balancer
+// resolves the backend ahead of all interceptors instead.
+func BalancerInterceptor(config BalancerConfig) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	return newBalancer(config).intercept
+}
+
+func newBalancer(config BalancerConfig) *balancer {
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = 3
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = defaultIsFailure
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	b := &balancer{config: config}
+	for _, be := range config.Backends {
+		u, err := url.Parse(be.URL)
+		if err != nil {
+			b.err = err
+			break
+		}
+		if be.Weight <= 0 {
+			be.Weight = 1
+		}
+		b.backends = append(b.backends, &backend{url: u, weight: be.Weight})
+	}
+	return b
+}
+
+func (b *balancer) intercept(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	return func(req Request) (Response, error) {
+		r := req.Request()
+		if len(r.URL.Host) > 0 {
+			return next(req)
+		}
+		if b.err != nil {
+			return nil, b.err
+		}
+		be := b.pick()
+		if be == nil {
+			return nil, ErrNoBackend
+		}
+		var sent int32
+		ctx := req.Context()
+		req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GetConn: func(string) { atomic.StoreInt32(&sent, 1) },
+		}))
+		r = req.Request()
+		r.URL = rewriteURL(r.URL, be.url)
+		r.Host = ""
+		resp, err := next(req)
+		var res *http.Response
+		if resp != nil {
+			res = resp.Response()
+		}
+		failed := false
+		if res != nil {
+			failed = b.config.IsFailure(res, err)
+		} else if atomic.LoadInt32(&sent) == 1 && ctx.Err() == nil {
+			// rate limits, canceled calls and alike never reached the backend
+			failed = b.config.IsFailure(nil, err)
+		}
+		b.done(be, failed)
+		if res == nil {
+			b.release(be)
+		} else {
+			OnBodyClose(res, func() { b.release(be) })
+		}
+		return resp, err
+	}
+}
+
+// rewriteURL returns a copy of u pointing to target, u is left untouched.
+func rewriteURL(u *url.URL, target *url.URL) *url.URL {
+	cp := *u
+	cp.Scheme, cp.Host, cp.User = target.Scheme, target.Host, target.User
+	if len(target.Path) > 0 {
+		cp.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(u.Path, "/")
+		cp.RawPath = strings.TrimSuffix(target.EscapedPath(), "/") + "/" + strings.TrimPrefix(u.EscapedPath(), "/")
+		if cp.RawPath == cp.Path {
+			cp.RawPath = ""
+		}
+	}
+	return &cp
+}
+
+func (b *balancer) pick() *backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.backends) == 0 {
+		return nil
+	}
+	now := b.config.Now()
+	candidates := make([]*backend, 0, len(b.backends))
+	for _, be := range b.backends {
+		if !be.ejectedUntil.After(now) {
+			candidates = append(candidates, be)
+		}
+	}
+	if len(candidates) == 0 {
+		first := b.backends[0]
+		for _, be := range b.backends[1:] {
+			if be.ejectedUntil.Before(first.ejectedUntil) {
+				first = be
+			}
+		}
+		candidates = append(candidates, first)
+	}
+
+	var chosen *backend
+	switch b.config.Strategy {
+	case LeastOutstanding:
+		for i := range candidates {
+			// start from a rotating offset so ties are spread
+			be := candidates[(b.next+i)%len(candidates)]
+			if chosen == nil || be.outstanding < chosen.outstanding {
+				chosen = be
+			}
+		}
+		b.next++
+	case Weighted:
+		total := 0
+		for _, be := range candidates {
+			be.current += be.weight
+			total += be.weight
+			if chosen == nil || be.current > chosen.current {
+				chosen = be
+			}
+		}
+		chosen.current -= total
+	default:
+		chosen = candidates[b.next%len(candidates)]
+		b.next++
+	}
+	chosen.outstanding++
+	return chosen
+}
+
+func (b *balancer) done(be *backend, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		be.failures = 0
+		return
+	}
+	be.failures++
+	if be.failures >= b.config.MaxFailures {
+		be.ejectedUntil = b.config.Now().Add(b.config.Cooldown)
+		// a single failure after the cooldown ejects it again
+		be.failures = b.config.MaxFailures - 1
+	}
+}
+
+func (b *balancer) release(be *backend) {
+	b.mu.Lock()
+	be.outstanding--
+	b.mu.Unlock()
+}
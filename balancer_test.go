@@ -0,0 +1,206 @@
+package xhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBalancer(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	var failing bool
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[name]++
+			fail := failing && name == "b"
+			mu.Unlock()
+			if fail {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte(name + " " + r.URL.Path))
+		}))
+	}
+	a, b := newServer("a"), newServer("b")
+	defer a.Close()
+	defer b.Close()
+
+	c := NewClient().BaseURLs(a.URL+"/api/", b.URL+"/api")
+	for i := 0; i < 4; i++ {
+		s, err := c.Get(context.TODO(), "/users").Do().String()
+		if err != nil || !strings.HasSuffix(s, " /api/users") {
+			t.Fatal(s, err)
+		}
+	}
+	if hits["a"] != 2 || hits["b"] != 2 {
+		t.Fatal("unexpected distribution", hits)
+	}
+
+	now := time.Now()
+	clock := func() time.Time { return now }
+	c = NewClient().Balancer(BalancerConfig{
+		Backends:    []Backend{{URL: a.URL}, {URL: b.URL}},
+		MaxFailures: 1,
+		Cooldown:    time.Minute,
+		Now:         clock,
+	})
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	hits = map[string]int{}
+	for i := 0; i < 6; i++ {
+		c.Get(context.TODO(), "/").Do().Bytes()
+	}
+	if hits["b"] != 1 || hits["a"] != 5 {
+		t.Fatal("failing backend not ejected", hits)
+	}
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	now = now.Add(2 * time.Minute)
+	hits = map[string]int{}
+	for i := 0; i < 4; i++ {
+		c.Get(context.TODO(), "/").Do().Bytes()
+	}
+	if hits["b"] != 2 {
+		t.Fatal("backend not admitted after cooldown", hits)
+	}
+
+	c = NewClient().Balancer(BalancerConfig{Backends: []Backend{{URL: a.URL, Weight: 3}, {URL: b.URL}}, Strategy: Weighted})
+	hits = map[string]int{}
+	for i := 0; i < 8; i++ {
+		c.Get(context.TODO(), "/").Do().Bytes()
+	}
+	if hits["a"] != 6 || hits["b"] != 2 {
+		t.Fatal("unexpected weighted distribution", hits)
+	}
+
+	c = NewClient().Balancer(BalancerConfig{Backends: []Backend{{URL: a.URL}, {URL: b.URL}}, Strategy: LeastOutstanding})
+	hits = map[string]int{}
+	open := c.Get(context.TODO(), "/").Do()
+	for i := 0; i < 3; i++ {
+		c.Get(context.TODO(), "/").Do().Bytes()
+	}
+	open.Bytes()
+	if hits["a"]+hits["b"] != 4 || (hits["a"] != 1 && hits["b"] != 1) {
+		t.Fatal("busy backend not avoided", hits)
+	}
+}
+
+func TestBalancerBeforeInterceptors(t *testing.T) {
+	hits := map[string]int{}
+	var mu sync.Mutex
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[name]++
+			mu.Unlock()
+			if r.URL.Path == "/events" {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte("data: " + name + "\n\n"))
+				return
+			}
+			w.Write([]byte(name))
+		}))
+	}
+	a, b := newServer("a"), newServer("b")
+	defer a.Close()
+	defer b.Close()
+
+	var hosts []string
+	c := NewClient().Interceptor(func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			hosts = append(hosts, req.Request().URL.Host)
+			return next(req)
+		}
+	}).BaseURLs(a.URL, b.URL)
+
+	req := c.Get(context.TODO(), "/")
+	for i := 0; i < 2; i++ {
+		if _, err := req.Do().String(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(req.Request().URL.Host) > 0 {
+		t.Fatal("url of the request rewritten", req.Request().URL)
+	}
+	if hits["a"] != 1 || hits["b"] != 1 {
+		t.Fatal("sent request not balanced again", hits)
+	}
+	for _, h := range hosts {
+		if len(h) == 0 {
+			t.Fatal("interceptor saw no host", hosts)
+		}
+	}
+
+	var data []string
+	err := c.Get(context.TODO(), "/events").Do().Events(func(e *Event) error {
+		if data = append(data, e.Data); len(data) == 2 {
+			return io.EOF
+		}
+		return nil
+	}, EventsConfig{Retry: time.Millisecond})
+	if err != io.EOF || len(data) != 2 || data[0] == data[1] {
+		t.Fatal("reconnect not balanced", data, err)
+	}
+}
+
+func TestBalancerFailures(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	newServer := func(name string, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[name]++
+			mu.Unlock()
+			w.WriteHeader(status)
+		}))
+	}
+	a, b := newServer("a", http.StatusOK), newServer("b", http.StatusNotFound)
+	defer a.Close()
+	defer b.Close()
+
+	// status errors of interceptors are not backend failures
+	c := NewClient().ExpectStatus().Balancer(BalancerConfig{Backends: []Backend{{URL: a.URL}, {URL: b.URL}}, MaxFailures: 1})
+	for i := 0; i < 8; i++ {
+		c.Get(context.TODO(), "/").Do().Bytes()
+	}
+	if hits["a"] != 4 || hits["b"] != 4 {
+		t.Fatal("backend answering 404 ejected", hits)
+	}
+
+	// nor are calls that never reached the backend
+	c = NewClient().Interceptor(RateLimitInterceptor(RateLimitConfig{Rate: 1e-3, Burst: 1, KeyFunc: func(Request) string { return "" }})).
+		Balancer(BalancerConfig{Backends: []Backend{{URL: a.URL}, {URL: b.URL}}, MaxFailures: 1})
+	hits = map[string]int{}
+	for i := 0; i < 4; i++ {
+		c.Get(context.TODO(), "/").Do().Bytes()
+	}
+	if hits["a"]+hits["b"] != 1 {
+		t.Fatal("want a single request", hits)
+	}
+	for _, be := range c.balancer.backends {
+		if be.failures > 0 || !be.ejectedUntil.IsZero() {
+			t.Fatal("rate limited call counted as failure of", be.url)
+		}
+	}
+
+	// connection errors are
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	c = NewClient().Balancer(BalancerConfig{Backends: []Backend{{URL: a.URL}, {URL: dead.URL}}, MaxFailures: 1})
+	hits = map[string]int{}
+	for i := 0; i < 6; i++ {
+		c.Get(context.TODO(), "/").Do().Bytes()
+	}
+	if hits["a"] != 5 {
+		t.Fatal("dead backend not ejected", hits)
+	}
+}
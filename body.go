@@ -1,9 +1,12 @@
 package xhttp
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 )
 
 func closeBody(body io.ReadCloser) error {
@@ -74,3 +77,87 @@ func ReadJSON(r *http.Request, v interface{}) error {
 func ReadXML(r *http.Request, v interface{}) error {
 	return ReadBody(r, XML, v)
 }
+
+type onCloseBody struct {
+	io.ReadCloser
+	once sync.Once
+	f    func()
+}
+
+func (b *onCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.f)
+	return err
+}
+
+// OnBodyClose arranges for f to be called once the response body is closed.
+// If the response has no body, f is called immediately.
+func OnBodyClose(res *http.Response, f func()) {
+	if res == nil || res.Body == nil || res.Body == http.NoBody {
+		f()
+		return
+	}
+	res.Body = &onCloseBody{ReadCloser: res.Body, f: f}
+}
+
+var ErrBodyNotRewindable = errors.New("request body can not be rewound")
+
+// readRequestBody returns the whole request body, leaving the request
+// ready to be sent again.
+func readRequestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	// reading the body is not sending it, so upload progress is not reported
+	if r.GetBody != nil {
+		rc, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		if pr, ok := rc.(*progressReader); ok {
+			rc = pr.ReadCloser
+		}
+		defer rc.Close()
+		return _ReadAll(rc)
+	}
+	if pr, ok := r.Body.(*progressReader); ok {
+		b, err := _ReadAll(pr.ReadCloser)
+		pr.ReadCloser.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.ContentLength = int64(len(b))
+		pr.ReadCloser = _NopCloser(bytes.NewReader(b))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return newProgressReader(_NopCloser(bytes.NewReader(b)), pr.total, pr.interval, pr.f), nil
+		}
+		return b, nil
+	}
+	b, err := _ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.ContentLength = int64(len(b))
+	r.Body = _NopCloser(bytes.NewReader(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return _NopCloser(bytes.NewReader(b)), nil
+	}
+	return b, nil
+}
+
+// rewindRequestBody resets the request body before sending the request again.
+func rewindRequestBody(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if r.GetBody == nil {
+		return ErrBodyNotRewindable
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return err
+	}
+	r.Body = body
+	return nil
+}
@@ -0,0 +1,299 @@
+package xhttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+var ErrInteractionNotFound = errors.New("cassette: no recorded interaction matches the request")
+
+const redacted = "REDACTED"
+
+type CassetteMode int
+
+const (
+	// CassetteReplay serves recorded interactions only, it is the default.
+	CassetteReplay CassetteMode = iota
+	// CassetteRecord calls the network and overwrites the cassette.
+	CassetteRecord
+	// CassetteReplayOrRecord replays matches and records everything else.
+	CassetteReplayOrRecord
+)
+
+type CassetteRequest struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 bool        `json:"body_base64,omitempty"`
+}
+
+type CassetteResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 bool        `json:"body_base64,omitempty"`
+}
+
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// CassetteMatcher reports whether r matches the recorded request.
+type CassetteMatcher func(r *CassetteRequest, recorded *CassetteRequest) bool
+
+func MatchMethod(r *CassetteRequest, recorded *CassetteRequest) bool {
+	return r.Method == recorded.Method
+}
+
+func MatchURL(r *CassetteRequest, recorded *CassetteRequest) bool {
+	return r.URL == recorded.URL
+}
+
+func MatchBody(r *CassetteRequest, recorded *CassetteRequest) bool {
+	return r.Body == recorded.Body && r.BodyBase64 == recorded.BodyBase64
+}
+
+func MatchHeaders(names ...string) CassetteMatcher {
+	return func(r *CassetteRequest, recorded *CassetteRequest) bool {
+		for _, name := range names {
+			a, b := r.Header.Values(name), recorded.Header.Values(name)
+			if len(a) != len(b) {
+				return false
+			}
+			for i := range a {
+				if a[i] != b[i] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
+
+type CassetteConfig struct {
+	File string
+
+	Mode CassetteMode
+
+	// Optional. Default value MatchMethod and MatchURL.
+	Matchers []CassetteMatcher
+
+	// Optional. Default value "Authorization", "Proxy-Authorization", "Cookie",
+	// "Set-Cookie" and "X-Api-Key". Values are replaced with "REDACTED".
+	RedactHeaders []string
+
+	// Query parameters replaced with "REDACTED".
+	RedactQuery []string
+
+	// Redact is called on every interaction before it is saved or matched.
+	Redact func(i *Interaction)
+}
+
+// Recorder records interactions to a cassette file and replays them.
+//
+//	rec, err := xhttp.NewRecorder(xhttp.CassetteConfig{File: "testdata/api.json"})
+//	c := xhttp.NewClient().Interceptor(rec.Interceptor)
+type Recorder struct {
+	config CassetteConfig
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     map[*Interaction]bool
+}
+
+func NewRecorder(config CassetteConfig) (*Recorder, error) {
+	if len(config.Matchers) == 0 {
+		config.Matchers = []CassetteMatcher{MatchMethod, MatchURL}
+	}
+	if config.RedactHeaders == nil {
+		config.RedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	}
+	rec := &Recorder{config: config, used: make(map[*Interaction]bool)}
+	if config.Mode == CassetteRecord {
+		return rec, nil
+	}
+	b, err := _ReadFile(config.File)
+	if err != nil {
+		if os.IsNotExist(err) && config.Mode == CassetteReplayOrRecord {
+			return rec, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &rec.cassette); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", config.File, err)
+	}
+	return rec, nil
+}
+
+func (rec *Recorder) Interceptor(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	return func(req Request) (Response, error) {
+		r := req.Request()
+		body, err := readRequestBody(r)
+		if err != nil {
+			return nil, err
+		}
+		cr := rec.redactRequest(CassetteRequest{Method: r.Method, URL: r.URL.String(), Header: r.Header.Clone()}, body)
+
+		if rec.config.Mode != CassetteRecord {
+			if i := rec.match(&cr); i != nil {
+				res, err := i.Response.response(r)
+				if err != nil {
+					return nil, err
+				}
+				return NewResponse(req, res, nil), nil
+			}
+			if rec.config.Mode == CassetteReplay {
+				return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, cr.Method, cr.URL)
+			}
+		}
+
+		resp, err := next(req)
+		if err != nil {
+			return resp, err
+		}
+		res := resp.Response()
+		resBody, err := _ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = _NopCloser(bytes.NewReader(resBody))
+		if err != nil {
+			return resp, err
+		}
+		i := &Interaction{Request: cr}
+		i.Response.StatusCode = res.StatusCode
+		i.Response.Header = res.Header.Clone()
+		i.Response.Body, i.Response.BodyBase64 = encodeCassetteBody(resBody)
+		rec.redactResponse(i)
+
+		rec.mu.Lock()
+		rec.cassette.Interactions = append(rec.cassette.Interactions, i)
+		rec.used[i] = true
+		rec.mu.Unlock()
+		return resp, rec.Save()
+	}
+}
+
+// match returns the first unused interaction matching r, reusing the
+// last match once all of them have been replayed.
+func (rec *Recorder) match(r *CassetteRequest) *Interaction {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var last *Interaction
+	for _, i := range rec.cassette.Interactions {
+		matched := true
+		for _, m := range rec.config.Matchers {
+			if !m(r, &i.Request) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if !rec.used[i] {
+			rec.used[i] = true
+			return i
+		}
+		last = i
+	}
+	return last
+}
+
+func (rec *Recorder) redactRequest(cr CassetteRequest, body []byte) CassetteRequest {
+	for _, h := range rec.config.RedactHeaders {
+		if _, ok := cr.Header[http.CanonicalHeaderKey(h)]; ok {
+			cr.Header.Set(h, redacted)
+		}
+	}
+	if len(rec.config.RedactQuery) > 0 {
+		if u, err := url.Parse(cr.URL); err == nil {
+			q := u.Query()
+			for _, k := range rec.config.RedactQuery {
+				if _, ok := q[k]; ok {
+					q.Set(k, redacted)
+				}
+			}
+			u.RawQuery = q.Encode()
+			cr.URL = u.String()
+		}
+	}
+	cr.Body, cr.BodyBase64 = encodeCassetteBody(body)
+	if rec.config.Redact != nil {
+		i := &Interaction{Request: cr}
+		rec.config.Redact(i)
+		cr = i.Request
+	}
+	return cr
+}
+
+func (rec *Recorder) redactResponse(i *Interaction) {
+	for _, h := range rec.config.RedactHeaders {
+		if _, ok := i.Response.Header[http.CanonicalHeaderKey(h)]; ok {
+			i.Response.Header.Set(h, redacted)
+		}
+	}
+	if rec.config.Redact != nil {
+		rec.config.Redact(i)
+	}
+}
+
+// Save writes the cassette file, it is called after every recorded interaction.
+func (rec *Recorder) Save() error {
+	rec.mu.Lock()
+	b, err := json.MarshalIndent(&rec.cassette, "", "  ")
+	rec.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(rec.config.File), 0755); err != nil {
+		return err
+	}
+	return _WriteFile(rec.config.File, b, 0644)
+}
+
+func (cr *CassetteResponse) response(req *http.Request) (*http.Response, error) {
+	body := []byte(cr.Body)
+	if cr.BodyBase64 {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(cr.Body); err != nil {
+			return nil, err
+		}
+	}
+	header := cr.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(cr.StatusCode) + " " + http.StatusText(cr.StatusCode),
+		StatusCode:    cr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          _NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func encodeCassetteBody(b []byte) (string, bool) {
+	if utf8.Valid(b) {
+		return string(b), false
+	}
+	return base64.StdEncoding.EncodeToString(b), true
+}
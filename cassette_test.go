@@ -0,0 +1,68 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := _ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		if r.URL.Path == "/bin" {
+			w.Write([]byte{0xff, 0x00, 0xfe})
+			return
+		}
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(b)))
+	}))
+
+	dir, err := _MkdirTemp("", "xhttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cassette.json")
+	config := CassetteConfig{
+		File:        file,
+		Matchers:    []CassetteMatcher{MatchMethod, MatchURL, MatchBody},
+		RedactQuery: []string{"token"},
+	}
+
+	config.Mode = CassetteRecord
+	rec, err := NewRecorder(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient().Interceptor(rec.Interceptor)
+	if s, _ := c.Post(context.TODO(), srv.URL+"/a?token=t1", "x").SetHeader("Authorization", "Bearer t1").Do().String(); s != "POST /a x" {
+		t.Fatal("unexpected response", s)
+	}
+	c.Get(context.TODO(), srv.URL+"/bin").Do().Bytes()
+	srv.Close()
+
+	b, _ := _ReadFile(file)
+	if strings.Contains(string(b), "t1") || strings.Contains(string(b), "secret") {
+		t.Fatal("secrets not redacted", string(b))
+	}
+
+	config.Mode = CassetteReplay
+	if rec, err = NewRecorder(config); err != nil {
+		t.Fatal(err)
+	}
+	c = NewClient().Interceptor(rec.Interceptor)
+	if s, err := c.Post(context.TODO(), srv.URL+"/a?token=t2", "x").Do().String(); err != nil || s != "POST /a x" {
+		t.Fatal("unexpected replay", s, err)
+	}
+	if b, err := c.Get(context.TODO(), srv.URL+"/bin").Do().Bytes(); err != nil || string(b) != "\xff\x00\xfe" {
+		t.Fatal("unexpected replay", b, err)
+	}
+	if err := c.Post(context.TODO(), srv.URL+"/a?token=t1", "y").Do().Error(); !errors.Is(err, ErrInteractionNotFound) {
+		t.Fatal("want ErrInteractionNotFound, got", err)
+	}
+}
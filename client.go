@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 var DefaultClient = NewClient()
@@ -14,11 +15,14 @@ func NewClient() Client {
 }
 
 type Client struct {
-	cli          *http.Client
-	baseURL      string
-	encoder      Encoder
-	decoder      Decoder
-	interceptors []func(next func(Request) (Response, error)) func(Request) (Response, error)
+	cli              *http.Client
+	baseURL          string
+	encoder          Encoder
+	decoder          Decoder
+	timeouts         Timeouts
+	progressInterval time.Duration
+	interceptors     []func(next func(Request) (Response, error)) func(Request) (Response, error)
+	balancer         *balancer
 }
 
 func (c Client) Ptr() *Client {
@@ -30,23 +34,46 @@ func (c *Client) Do(req Request) Response {
 		return &response{err: err, cli: c}
 	}
 	interceptors := c.interceptors
+	ctx, origin := req.Context(), req.Request()
+	if r, ok := req.(interface{ forCall() Request }); ok {
+		req = r.forCall()
+	}
+
+	total := c.timeouts.Total
+	if r, ok := req.(interface{ timeout() time.Duration }); ok && r.timeout() > 0 {
+		total = r.timeout()
+	}
+	var tt *totalTimeout
+	if total > 0 {
+		tt = newTotalTimeout(ctx, total)
+		req.WithContext(tt.ctx)
+	}
 
 	var h = func(req Request) (Response, error) {
 		if err := req.Error(); err != nil {
 			return &response{err: err, cli: c}, err
 		}
-		res, err := c.cli.Do(req.Request())
+		res, err := c.roundTrip(req.Request())
 		return &response{err: err, cli: c, res: res}, err
 	}
 
 	for i := len(interceptors) - 1; i >= 0; i-- {
 		h = interceptors[i](h)
 	}
+	if c.balancer != nil {
+		h = c.balancer.intercept(h)
+	}
 
 	resp, err := h(req)
+	if tt != nil {
+		err = tt.done(resp, err)
+	}
 	if resp == nil {
 		resp = &response{err: err, cli: c}
 	}
+	if r, ok := resp.(*response); ok {
+		r.ctx, r.origin = ctx, origin
+	}
 	resp.SetError(err)
 	return resp
 }
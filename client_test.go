@@ -1,18 +1,59 @@
 package xhttp
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
+func newGzipServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			WriteString(w, http.StatusOK, "", body)
+			return
+		}
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(body))
+		zw.Close()
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(buf.Bytes())
+	}))
+}
+
 func TestGet(t *testing.T) {
+	srv := newGzipServer("hello")
+	defer srv.Close()
+
 	c := NewClient().Gzip(true).ResponseInterceptor(WhetherStatusCode(200))
-	res, err := c.Get(context.TODO(), "https://www.baidu.com").Do().String()
-	t.Log(res, err)
+	res, err := c.Get(context.TODO(), srv.URL).Do().String()
+	if err != nil || res != "hello" {
+		t.Fatal(res, err)
+	}
 }
 
 func TestFile(t *testing.T) {
+	srv := newGzipServer("hello")
+	defer srv.Close()
+
+	dir, err := _MkdirTemp("", "xhttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "testdata-file.txt")
+
 	c := NewClient().Gzip(true).ResponseInterceptor(WhetherStatusCode(200))
-	n, err := c.Get(context.TODO(), "https://www.baidu.com").Do().File("testdata-file.txt", 0755)
-	t.Log(n, err)
+	n, err := c.Get(context.TODO(), srv.URL).Do().File(file, 0755)
+	if err != nil || n != 5 {
+		t.Fatal(n, err)
+	}
+	if b, err := _ReadFile(file); err != nil || string(b) != "hello" {
+		t.Fatal(string(b), err)
+	}
 }
@@ -0,0 +1,218 @@
+package xhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar sets the jar of a copy of the underlying http.Client.
+func (c Client) CookieJar(jar http.CookieJar) Client {
+	cli := *c.cli
+	cli.Jar = jar
+	c.cli = &cli
+	return c
+}
+
+// Cookies returns the cookies the jar would send to u, nil without a jar.
+func (c Client) Cookies(u string) ([]*http.Cookie, error) {
+	if c.cli.Jar == nil {
+		return nil, nil
+	}
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	return c.cli.Jar.Cookies(pu), nil
+}
+
+func (c Client) SetCookies(u string, cookies ...*http.Cookie) error {
+	if c.cli.Jar == nil {
+		return nil
+	}
+	pu, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	c.cli.Jar.SetCookies(pu, cookies)
+	return nil
+}
+
+type PersistentCookieJarConfig struct {
+	// File the cookies are loaded from and saved to.
+	File string
+
+	// PublicSuffixList keeps cookies from being set for a public suffix
+	// such as "co.uk", see golang.org/x/net/publicsuffix.
+	PublicSuffixList cookiejar.PublicSuffixList
+
+	// KeepSessionCookies saves cookies without an expiry as well.
+	KeepSessionCookies bool
+
+	// AutoSave saves the file every time cookies are set.
+	AutoSave bool
+}
+
+type savedCookie struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+// PersistentCookieJar is a cookiejar.Jar whose cookies survive restarts.
+type PersistentCookieJar struct {
+	config PersistentCookieJarConfig
+	jar    *cookiejar.Jar
+
+	mu      sync.Mutex
+	entries map[string]savedCookie
+}
+
+var _ http.CookieJar = (*PersistentCookieJar)(nil)
+
+// NewPersistentCookieJar loads the cookies of config.File when it exists.
+func NewPersistentCookieJar(config PersistentCookieJarConfig) (*PersistentCookieJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: config.PublicSuffixList})
+	if err != nil {
+		return nil, err
+	}
+	j := &PersistentCookieJar{config: config, jar: jar, entries: make(map[string]savedCookie)}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *PersistentCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+func (j *PersistentCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	j.mu.Lock()
+	for _, c := range cookies {
+		key, ok := cookieKey(u, c)
+		if !ok {
+			continue
+		}
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && !c.Expires.After(now)) {
+			delete(j.entries, key)
+			continue
+		}
+		saved := *c
+		if c.MaxAge > 0 {
+			saved.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+			saved.MaxAge = 0
+		}
+		saved.Raw = ""
+		saved.RawExpires = ""
+		j.entries[key] = savedCookie{URL: (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(), Cookie: &saved}
+	}
+	j.mu.Unlock()
+
+	if j.config.AutoSave {
+		j.Save()
+	}
+}
+
+// Save writes the cookies that have not expired to the file.
+func (j *PersistentCookieJar) Save() error {
+	now := time.Now()
+	j.mu.Lock()
+	saved := make([]savedCookie, 0, len(j.entries))
+	for key, e := range j.entries {
+		if e.Cookie.Expires.IsZero() {
+			if !j.config.KeepSessionCookies {
+				continue
+			}
+		} else if !e.Cookie.Expires.After(now) {
+			delete(j.entries, key)
+			continue
+		}
+		saved = append(saved, e)
+	}
+	j.mu.Unlock()
+
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.config.File), 0755); err != nil {
+		return err
+	}
+	f, err := _CreateTemp(filepath.Dir(j.config.File), filepath.Base(j.config.File)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Chmod(0600)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), j.config.File)
+}
+
+func (j *PersistentCookieJar) load() error {
+	b, err := _ReadFile(j.config.File)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved []savedCookie
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, e := range saved {
+		if e.Cookie == nil || (!e.Cookie.Expires.IsZero() && !e.Cookie.Expires.After(now)) {
+			continue
+		}
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+		key, ok := cookieKey(u, e.Cookie)
+		if !ok {
+			continue
+		}
+		j.jar.SetCookies(u, []*http.Cookie{e.Cookie})
+		j.entries[key] = e
+	}
+	return nil
+}
+
+// cookieKey identifies a cookie by its effective domain, path and name like
+// net/http/cookiejar does, so any host of the domain can replace or delete it.
+func cookieKey(u *url.URL, c *http.Cookie) (string, bool) {
+	host := strings.ToLower(u.Hostname())
+	domain := host
+	if len(c.Domain) > 0 {
+		domain = strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			// the jar rejects it as well
+			return "", false
+		}
+	}
+	path := c.Path
+	if len(path) == 0 || path[0] != '/' {
+		path = "/"
+		if i := strings.LastIndex(u.Path, "/"); i > 0 {
+			path = u.Path[:i]
+		}
+	}
+	return domain + ";" + path + ";" + c.Name, true
+}
@@ -0,0 +1,106 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersistentCookieJar(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "remember", Value: "r1", Path: "/", MaxAge: 3600})
+			http.SetCookie(w, &http.Cookie{Name: "old", Value: "o1", Path: "/", Expires: time.Now().Add(-time.Hour)})
+		default:
+			if c, err := r.Cookie("remember"); err == nil {
+				w.Write([]byte(c.Value))
+			}
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := _MkdirTemp("", "xhttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cookies.json")
+	jar, err := NewPersistentCookieJar(PersistentCookieJarConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient().CookieJar(jar)
+	if NewClient().Client().Jar != nil {
+		t.Fatal("jar leaked into another client")
+	}
+	if err := c.Get(context.TODO(), srv.URL+"/login").Do().Error(); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := c.Get(context.TODO(), srv.URL+"/me").Do().String(); s != "r1" {
+		t.Fatal("cookie not sent, got", s)
+	}
+	if cookies, _ := c.Cookies(srv.URL); len(cookies) != 2 {
+		t.Fatal("want 2 cookies, got", cookies)
+	}
+	if err := jar.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	jar, err = NewPersistentCookieJar(PersistentCookieJarConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = NewClient().CookieJar(jar)
+	cookies, _ := c.Cookies(srv.URL)
+	if len(cookies) != 1 || cookies[0].Name != "remember" {
+		t.Fatal("want only the persistent cookie, got", cookies)
+	}
+	if s, _ := c.Get(context.TODO(), srv.URL+"/me").Do().String(); s != "r1" {
+		t.Fatal("restored cookie not sent, got", s)
+	}
+
+	c.SetCookies(srv.URL, &http.Cookie{Name: "remember", MaxAge: -1})
+	if cookies, _ := c.Cookies(srv.URL); len(cookies) != 0 {
+		t.Fatal("cookie not deleted", cookies)
+	}
+}
+
+func TestPersistentCookieJarDomain(t *testing.T) {
+	dir, err := _MkdirTemp("", "xhttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cookies.json")
+	jar, err := NewPersistentCookieJar(PersistentCookieJarConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := url.Parse("http://a.example.com/app/login")
+	b, _ := url.Parse("http://b.example.com:8080/")
+	jar.SetCookies(a, []*http.Cookie{
+		{Name: "sid", Value: "1", Domain: ".example.com", Path: "/", MaxAge: 3600},
+		{Name: "app", Value: "2", MaxAge: 3600},
+	})
+	jar.SetCookies(b, []*http.Cookie{{Name: "sid", Domain: "Example.com", Path: "/", MaxAge: -1}})
+	a.Path = "/app/"
+	jar.SetCookies(a, []*http.Cookie{{Name: "app", MaxAge: -1}})
+	if err := jar.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	jar, err = NewPersistentCookieJar(PersistentCookieJarConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookies := jar.Cookies(a); len(cookies) != 0 {
+		t.Fatal("deleted cookies restored", cookies)
+	}
+}
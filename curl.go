@@ -0,0 +1,183 @@
+package xhttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// Curl prepares the request and renders it as a curl command.
+func (r *request) Curl() (string, error) {
+	return r.curl(nil)
+}
+
+func (r *request) curl(redact []string) (string, error) {
+	if err := r.Perpare(); err != nil {
+		return "", err
+	}
+	req := r.req
+	var b strings.Builder
+	b.WriteString("curl")
+
+	var body []byte
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if hasBody && !r.multipart {
+		var err error
+		if body, err = readRequestBody(req); err != nil {
+			return "", err
+		}
+	}
+	switch {
+	case req.Method == "HEAD" && !hasBody:
+		// -X HEAD makes curl wait for a body
+		b.WriteString(" --head")
+	case req.Method != "GET" || hasBody:
+		b.WriteString(" -X " + shellQuote(req.Method))
+	}
+	b.WriteString(" " + shellQuote(req.URL.String()))
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		if r.multipart && k == "Content-Type" {
+			// curl writes its own boundary
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			if containsFold(redact, k) {
+				v = redacted
+			}
+			b.WriteString(" -H " + shellQuote(k+": "+v))
+		}
+	}
+	if len(req.Host) > 0 && req.Host != req.URL.Host {
+		b.WriteString(" -H " + shellQuote("Host: "+req.Host))
+	}
+
+	if r.multipart {
+		for _, p := range r.parts {
+			if !p.isFile {
+				b.WriteString(" --form-string " + shellQuote(p.name+"="+p.value))
+				continue
+			}
+			f, err := curlFilePart(p)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(" -F " + shellQuote(f))
+		}
+	} else if hasBody {
+		b.WriteString(" --data-binary " + shellQuote(string(body)))
+	}
+	return b.String(), nil
+}
+
+// maxCurlInlinePart is the largest in-memory part written inline.
+const maxCurlInlinePart = 4 << 10
+
+// curlFilePart renders a file part as a -F argument. Parts that are not
+// read from disk are inlined when they are short text, otherwise they
+// refer to a placeholder file that has to be created to replay them.
+func curlFilePart(p *formPart) (string, error) {
+	var f string
+	switch {
+	case len(p.path) > 0:
+		f = p.name + "=@" + formQuote(p.path)
+		if filepath.Base(p.path) != p.filename {
+			f += ";filename=" + formQuote(p.filename)
+		}
+	default:
+		var content []byte
+		if p.reopenable && p.size >= 0 && p.size <= maxCurlInlinePart {
+			rc, err := p.open()
+			if err != nil {
+				return "", err
+			}
+			content, err = _ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+		if content != nil && utf8.Valid(content) && bytes.IndexByte(content, 0) < 0 {
+			f = p.name + "=" + formQuote(string(content))
+		} else {
+			f = p.name + "=@" + formQuote("<in-memory "+p.filename+">")
+		}
+		f += ";filename=" + formQuote(p.filename)
+	}
+	if len(p.contentType) > 0 {
+		f += ";type=" + p.contentType
+	}
+	return f, nil
+}
+
+// formQuote double quotes a value of a -F argument when curl would split
+// it or read it from a file.
+func formQuote(s string) string {
+	if strings.ContainsAny(s, `;,"\`) || strings.HasPrefix(s, "@") || strings.HasPrefix(s, "<") || s != strings.TrimSpace(s) {
+		return `"` + quoteEscaper.Replace(s) + `"`
+	}
+	return s
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	if len(s) > 0 && strings.IndexFunc(s, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./:@=,+%", c))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+type CurlConfig struct {
+	// Optional. Default value os.Stderr.
+	Output io.Writer
+
+	// Optional. Default value "Authorization", "Proxy-Authorization", "Cookie"
+	// and "X-Api-Key". Values are replaced with "REDACTED".
+	RedactHeaders []string
+}
+
+func (c Client) Curl(output io.Writer) Client {
+	return c.Interceptor(CurlInterceptor(CurlConfig{Output: output}))
+}
+
+// CurlInterceptor writes every request as a curl command before it is sent.
+func CurlInterceptor(config CurlConfig) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	if config.Output == nil {
+		config.Output = os.Stderr
+	}
+	if config.RedactHeaders == nil {
+		config.RedactHeaders = defaultRedactHeaders
+	}
+	return func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			var cmd string
+			var err error
+			if r, ok := req.(interface {
+				curl(redact []string) (string, error)
+			}); ok {
+				cmd, err = r.curl(config.RedactHeaders)
+			} else {
+				cmd, err = req.Curl()
+			}
+			if err != nil {
+				return nil, err
+			}
+			io.WriteString(config.Output, cmd+"\n")
+			return next(req)
+		}
+	}
+}
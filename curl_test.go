@@ -0,0 +1,66 @@
+package xhttp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCurl(t *testing.T) {
+	c := NewClient()
+	cmd, err := c.Post(context.TODO(), "http://example.com/a b?x=1", `{"name":"it's"}`).JSON().Query("y", "2").Curl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `curl -X POST 'http://example.com/a%20b?x=1&y=2' -H 'Content-Type: application/json' --data-binary '{"name":"it'\''s"}'`
+	if cmd != want {
+		t.Fatal("unexpected command\n", cmd, "\n", want)
+	}
+
+	cmd, _ = c.Post(context.TODO(), "http://example.com/upload", nil).
+		Field("title", "a b").FileBytes("file", "a.txt", []byte("x"), "text/plain").Curl()
+	want = `curl -X POST http://example.com/upload --form-string 'title=a b' -F 'file=x;filename=a.txt;type=text/plain'`
+	if cmd != want {
+		t.Fatal("unexpected command\n", cmd, "\n", want)
+	}
+
+	cmd, _ = c.Post(context.TODO(), "http://example.com/upload", nil).
+		FileBytes("a", "a;b,c.txt", []byte("@x;y"), "").
+		FileBytes("b", "b.bin", []byte{0, 1}, "").
+		FileReader("c", "c.txt", strings.NewReader("z"), "").Curl()
+	want = `curl -X POST http://example.com/upload -F 'a="@x;y";filename="a;b,c.txt"' -F 'b=@"<in-memory b.bin>";filename=b.bin' -F 'c=@"<in-memory c.txt>";filename=c.txt'`
+	if cmd != want {
+		t.Fatal("unexpected command\n", cmd, "\n", want)
+	}
+
+	cmd, _ = c.Head(context.TODO(), "http://example.com/", nil).Curl()
+	if want = `curl --head http://example.com/`; cmd != want {
+		t.Fatal("unexpected command\n", cmd, "\n", want)
+	}
+
+	var progress int
+	cmd, _ = c.Post(context.TODO(), "http://example.com/", "x").UploadProgress(func(int64, int64) { progress++ }).Curl()
+	if want = `curl -X POST http://example.com/ --data-binary x`; cmd != want || progress != 0 {
+		t.Fatal("unexpected command\n", cmd, "\n", want, progress)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := _ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	c = NewClient().Interceptor(CurlInterceptor(CurlConfig{Output: &buf}))
+	s, err := c.Put(context.TODO(), srv.URL+"?q=1", "body").SetHeader("Authorization", "Bearer t").Do().String()
+	if err != nil || s != "body" {
+		t.Fatal("body not replayed", s, err)
+	}
+	want = `curl -X PUT '` + srv.URL + `?q=1' -H 'Authorization: REDACTED' --data-binary body` + "\n"
+	if buf.String() != want {
+		t.Fatal("unexpected log\n", buf.String(), want)
+	}
+}
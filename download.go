@@ -0,0 +1,419 @@
+package xhttp
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrSizeMismatch     = errors.New("download size mismatch")
+	ErrChecksumMismatch = errors.New("download checksum mismatch")
+)
+
+type DownloadConfig struct {
+	// Resume continues a previous partial download kept in "<file>.download".
+	Resume bool
+
+	// Concurrency splits the file into parallel ranged requests when the
+	// server accepts ranges. Parallel downloads are not resumed.
+	Concurrency int
+
+	// Optional. Default value 4 MB. Files smaller than two chunks are not split.
+	ChunkSize int64
+
+	// Size is verified when greater than 0.
+	Size int64
+
+	// Checksum is the hex encoded digest of Hash, verified when both are set.
+	Hash     func() hash.Hash
+	Checksum string
+
+	// Optional. Default value 0644.
+	Perm os.FileMode
+
+	Header http.Header
+
+	Progress func(received int64, total int64)
+}
+
+type downloadMeta struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Size         int64  `json:"size"`
+}
+
+// Download writes the content of url to file. The content is written to
+// a temporary file first, verified, and then renamed over file.
+func (c Client) Download(ctx context.Context, url string, file string, config DownloadConfig) (int64, error) {
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = 4 << 20
+	}
+	if config.Perm == 0 {
+		config.Perm = 0644
+	}
+	d := &download{cli: &c, ctx: ctx, url: url, config: config, tmp: file + ".download"}
+	d.metaFile = d.tmp + ".meta"
+
+	n, err := d.run()
+	if err != nil {
+		// a failed parallel download has holes, it can not be resumed
+		if !config.Resume || d.parallelized || errors.Is(err, ErrSizeMismatch) || errors.Is(err, ErrChecksumMismatch) {
+			os.Remove(d.tmp)
+			os.Remove(d.metaFile)
+		}
+		return n, err
+	}
+	if err = os.Rename(d.tmp, file); err != nil {
+		return n, err
+	}
+	os.Remove(d.metaFile)
+	return n, nil
+}
+
+type download struct {
+	cli      *Client
+	ctx      context.Context
+	url      string
+	config   DownloadConfig
+	tmp      string
+	metaFile string
+
+	restarted    bool
+	parallelized bool
+
+	mu       sync.Mutex
+	received int64
+}
+
+func (d *download) request(method string) Request {
+	req := d.cli.Request(d.ctx, method, d.url, nil)
+	for k, vs := range d.config.Header {
+		for _, v := range vs {
+			req.AddHeader(k, v)
+		}
+	}
+	return req
+}
+
+func (d *download) run() (int64, error) {
+	var size int64
+	var err error
+	if d.config.Concurrency > 1 {
+		var meta *downloadMeta
+		if meta, err = d.probe(); err != nil {
+			return 0, err
+		}
+		if meta != nil && meta.Size >= 2*d.config.ChunkSize {
+			d.parallelized = true
+			size, err = d.parallel(meta)
+		} else {
+			size, err = d.single()
+		}
+	} else {
+		size, err = d.single()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return size, d.verify(size)
+}
+
+// probe reports the entity of url if the server accepts ranges.
+func (d *download) probe() (*downloadMeta, error) {
+	resp := d.request("HEAD").Do()
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+	res := resp.Response()
+	res.Body.Close()
+	if err := WhetherStatusCode(http.StatusOK)(resp); err != nil {
+		return nil, err
+	}
+	if res.Header.Get("Accept-Ranges") != "bytes" || res.ContentLength <= 0 {
+		return nil, nil
+	}
+	return &downloadMeta{ETag: res.Header.Get("ETag"), LastModified: res.Header.Get("Last-Modified"), Size: res.ContentLength}, nil
+}
+
+func (d *download) single() (int64, error) {
+	f, err := os.OpenFile(d.tmp, os.O_CREATE|os.O_WRONLY, d.config.Perm)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var offset int64
+	var meta *downloadMeta
+	if d.config.Resume && !d.restarted {
+		if fi, err := f.Stat(); err == nil {
+			offset = fi.Size()
+		}
+		meta = d.loadMeta()
+		// without a strong validator If-Range can not tell a changed entity
+		if meta == nil || (!strongETag(meta.ETag) && len(meta.LastModified) == 0) {
+			offset = 0
+		}
+	}
+
+	req := d.request("GET")
+	if offset > 0 {
+		req.SetHeader("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		if strongETag(meta.ETag) {
+			req.SetHeader("If-Range", meta.ETag)
+		} else {
+			req.SetHeader("If-Range", meta.LastModified)
+		}
+	}
+	resp := req.Do()
+	if err := resp.Error(); err != nil {
+		return 0, err
+	}
+	res := resp.Response()
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		start, total, ok := parseContentRange(res.Header.Get("Content-Range"))
+		if !ok || start != offset || meta == nil {
+			return 0, fmt.Errorf("unexpected content range %q", res.Header.Get("Content-Range"))
+		}
+		if total < 0 && meta != nil {
+			total = meta.Size
+		}
+		meta.Size = total
+	case http.StatusRequestedRangeNotSatisfiable:
+		if meta != nil && meta.Size == offset {
+			return offset, nil
+		}
+		// the partial file does not match the entity, start over
+		res.Body.Close()
+		f.Close()
+		d.restarted = true
+		return d.single()
+	default:
+		if err := WhetherStatusCode(http.StatusOK)(resp); err != nil {
+			return 0, err
+		}
+		offset = 0
+		meta = &downloadMeta{ETag: res.Header.Get("ETag"), LastModified: res.Header.Get("Last-Modified"), Size: res.ContentLength}
+	}
+	if err := f.Truncate(offset); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if d.config.Resume {
+		if err := d.saveMeta(meta); err != nil {
+			return 0, err
+		}
+	}
+
+	var body io.Reader = res.Body
+	if d.config.Progress != nil {
+		total := meta.Size
+		body = newProgressReader(res.Body, res.ContentLength, d.cli.progressInterval, func(n int64, _ int64) {
+			d.config.Progress(offset+n, total)
+		})
+	}
+	n, err := io.Copy(f, body)
+	if err != nil {
+		return offset + n, err
+	}
+	if err := f.Sync(); err != nil {
+		return offset + n, err
+	}
+	return offset + n, nil
+}
+
+func (d *download) parallel(meta *downloadMeta) (int64, error) {
+	// the meta of an earlier single stream does not describe this file
+	os.Remove(d.metaFile)
+	f, err := os.OpenFile(d.tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, d.config.Perm)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := f.Truncate(meta.Size); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	defer cancel()
+
+	chunks := make(chan [2]int64)
+	go func() {
+		defer close(chunks)
+		for start := int64(0); start < meta.Size; start += d.config.ChunkSize {
+			end := start + d.config.ChunkSize - 1
+			if end >= meta.Size {
+				end = meta.Size - 1
+			}
+			select {
+			case chunks <- [2]int64{start, end}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i := 0; i < d.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				if err := d.chunk(ctx, f, meta, chunk[0], chunk[1]); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	return meta.Size, nil
+}
+
+func (d *download) chunk(ctx context.Context, f *os.File, meta *downloadMeta, start int64, end int64) error {
+	req := d.request("GET").WithContext(ctx)
+	req.SetHeader("Range", "bytes="+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10))
+	if strongETag(meta.ETag) {
+		req.SetHeader("If-Range", meta.ETag)
+	} else if len(meta.LastModified) > 0 {
+		req.SetHeader("If-Range", meta.LastModified)
+	}
+	resp := req.Do()
+	if err := resp.Error(); err != nil {
+		return err
+	}
+	res := resp.Response()
+	defer res.Body.Close()
+	if err := WhetherStatusCode(http.StatusPartialContent)(resp); err != nil {
+		return err
+	}
+	if s, _, ok := parseContentRange(res.Header.Get("Content-Range")); !ok || s != start {
+		return fmt.Errorf("unexpected content range %q", res.Header.Get("Content-Range"))
+	}
+	w := &offsetWriter{f: f, off: start}
+	var body io.Reader = io.LimitReader(res.Body, end-start+1)
+	n, err := io.Copy(w, body)
+	if d.config.Progress != nil {
+		d.mu.Lock()
+		d.received += n
+		d.config.Progress(d.received, meta.Size)
+		d.mu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	if n != end-start+1 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (d *download) verify(size int64) error {
+	fi, err := os.Stat(d.tmp)
+	if err != nil {
+		return err
+	}
+	if fi.Size() != size || (d.config.Size > 0 && fi.Size() != d.config.Size) {
+		return ErrSizeMismatch
+	}
+	if d.config.Hash == nil || len(d.config.Checksum) == 0 {
+		return nil
+	}
+	f, err := os.Open(d.tmp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := d.config.Hash()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), d.config.Checksum) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+func (d *download) loadMeta() *downloadMeta {
+	b, err := _ReadFile(d.metaFile)
+	if err != nil {
+		return nil
+	}
+	var meta downloadMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return nil
+	}
+	return &meta
+}
+
+func (d *download) saveMeta(meta *downloadMeta) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return _WriteFile(d.metaFile, b, 0644)
+}
+
+func strongETag(etag string) bool {
+	return len(etag) > 0 && !strings.HasPrefix(etag, "W/")
+}
+
+// parseContentRange parses "bytes start-end/total", total is -1 if unknown.
+func parseContentRange(s string) (start int64, total int64, ok bool) {
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, false
+	}
+	s = s[len("bytes "):]
+	p := strings.Index(s, "/")
+	q := strings.Index(s, "-")
+	if p < 0 || q < 0 || q > p {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(s[:q], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	total = -1
+	if s[p+1:] != "*" {
+		if total, err = strconv.ParseInt(s[p+1:], 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, total, true
+}
+
+type offsetWriter struct {
+	f   *os.File
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
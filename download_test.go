@@ -0,0 +1,105 @@
+package xhttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	sum := sha256.Sum256(content)
+	var ranged, failing int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rg := r.Header.Get("Range"); rg != "" {
+			atomic.AddInt32(&ranged, 1)
+			if atomic.LoadInt32(&failing) == 1 && rg != "bytes=0-16383" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		if r.URL.Path == "/weak" {
+			w.Header().Set("ETag", `W/"v1"`)
+		} else {
+			w.Header().Set("ETag", `"v1"`)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir, err := _MkdirTemp("", "xhttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "a.bin")
+
+	check := func(n int64, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := _ReadFile(file)
+		if n != int64(len(content)) || !bytes.Equal(b, content) {
+			t.Fatal("wrong content", n, len(b))
+		}
+		if _, err := os.Stat(file + ".download"); !os.IsNotExist(err) {
+			t.Fatal("temp file left behind")
+		}
+	}
+
+	// an older, longer file is replaced
+	_WriteFile(file, bytes.Repeat([]byte("x"), 200000), 0644)
+	check(NewClient().Download(context.TODO(), srv.URL, file, DownloadConfig{
+		Hash:     sha256.New,
+		Checksum: hex.EncodeToString(sum[:]),
+	}))
+
+	// resume a partial download
+	_WriteFile(file+".download", content[:5000], 0644)
+	_WriteFile(file+".download.meta", []byte(`{"etag":"\"v1\"","size":100000}`), 0644)
+	atomic.StoreInt32(&ranged, 0)
+	check(NewClient().Download(context.TODO(), srv.URL, file, DownloadConfig{Resume: true}))
+	if ranged != 1 {
+		t.Fatal("want ranged request")
+	}
+
+	// parallel chunks
+	atomic.StoreInt32(&ranged, 0)
+	check(NewClient().Download(context.TODO(), srv.URL, file, DownloadConfig{Concurrency: 4, ChunkSize: 16 << 10}))
+	if ranged != 7 {
+		t.Fatal("want 7 chunks, got", ranged)
+	}
+
+	// a failed parallel download is not resumed over the meta of a single stream
+	_WriteFile(file+".download", content[:5000], 0644)
+	_WriteFile(file+".download.meta", []byte(`{"etag":"\"v1\"","size":100000}`), 0644)
+	atomic.StoreInt32(&failing, 1)
+	if _, err := NewClient().Download(context.TODO(), srv.URL, file, DownloadConfig{Resume: true, Concurrency: 4, ChunkSize: 16 << 10}); err == nil {
+		t.Fatal("want failed chunks")
+	}
+	atomic.StoreInt32(&failing, 0)
+	check(NewClient().Download(context.TODO(), srv.URL, file, DownloadConfig{Resume: true}))
+
+	// a weak etag can not validate a range
+	_WriteFile(file+".download", bytes.Repeat([]byte("x"), 5000), 0644)
+	_WriteFile(file+".download.meta", []byte(`{"etag":"W/\"v1\"","size":100000}`), 0644)
+	atomic.StoreInt32(&ranged, 0)
+	check(NewClient().Download(context.TODO(), srv.URL+"/weak", file, DownloadConfig{Resume: true}))
+	if ranged != 0 {
+		t.Fatal("resumed without a strong validator")
+	}
+
+	_, err = NewClient().Download(context.TODO(), srv.URL, file, DownloadConfig{Hash: sha256.New, Checksum: "00"})
+	if err != ErrChecksumMismatch {
+		t.Fatal("want ErrChecksumMismatch, got", err)
+	}
+}
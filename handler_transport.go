@@ -0,0 +1,189 @@
+package xhttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// ErrHandlerPanic is returned when the handler panics before writing the header.
+var ErrHandlerPanic = errors.New("handler panic")
+
+// HandlerTransport is a http.RoundTripper serving requests with Handler
+// in-process. The response body is streamed from the handler as it writes.
+type HandlerTransport struct {
+	Handler http.Handler
+
+	// Optional. Default value "192.0.2.1:1234".
+	RemoteAddr string
+}
+
+var _ http.RoundTripper = (*HandlerTransport)(nil)
+
+// NewClientForHandler returns a Client whose requests are served by h.
+func NewClientForHandler(h http.Handler) Client {
+	return NewClient().WithClient(&http.Client{Transport: &HandlerTransport{Handler: h}})
+}
+
+func (t *HandlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	r, err := t.serverRequest(req)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	w := &handlerResponseWriter{header: make(http.Header), pw: pw, ready: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+		}()
+		defer func() {
+			if v := recover(); v != nil {
+				err := fmt.Errorf("%w: %v", ErrHandlerPanic, v)
+				w.commit(err)
+				pw.CloseWithError(err)
+				return
+			}
+			w.commit(nil)
+			pw.CloseWithError(ctx.Err())
+		}()
+		t.Handler.ServeHTTP(w, r)
+	}()
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		pw.CloseWithError(ctx.Err())
+		return nil, ctx.Err()
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			pw.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	res := &http.Response{
+		Status:        strconv.Itoa(w.code) + " " + http.StatusText(w.code),
+		StatusCode:    w.code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.snapshot,
+		Body:          pr,
+		ContentLength: -1,
+		Request:       req,
+	}
+	if n, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64); err == nil {
+		res.ContentLength = n
+	}
+	if req.Method == "HEAD" || w.code == http.StatusNoContent || w.code == http.StatusNotModified {
+		pr.Close()
+		res.Body = http.NoBody
+		if req.Method != "HEAD" {
+			res.ContentLength = 0
+		}
+	}
+	return res, nil
+}
+
+func (t *HandlerTransport) serverRequest(req *http.Request) (*http.Request, error) {
+	u, err := url.ParseRequestURI(req.URL.RequestURI())
+	if err != nil {
+		return nil, err
+	}
+	r := req.WithContext(req.Context())
+	r.URL = u
+	r.RequestURI = req.URL.RequestURI()
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/1.1", 1, 1
+	r.Header = req.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	if len(r.Host) == 0 {
+		r.Host = req.URL.Host
+	}
+	if req.Body == nil {
+		r.Body = http.NoBody
+	}
+	r.GetBody = nil
+	r.RemoteAddr = t.RemoteAddr
+	if len(r.RemoteAddr) == 0 {
+		r.RemoteAddr = "192.0.2.1:1234"
+	}
+	if req.URL.Scheme == "https" {
+		r.TLS = &tls.ConnectionState{
+			Version:           tls.VersionTLS12,
+			HandshakeComplete: true,
+			ServerName:        req.URL.Hostname(),
+		}
+	}
+	return r, nil
+}
+
+type handlerResponseWriter struct {
+	header   http.Header
+	snapshot http.Header
+	code     int
+	pw       *io.PipeWriter
+
+	once  sync.Once
+	ready chan struct{}
+	err   error
+}
+
+func (w *handlerResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *handlerResponseWriter) WriteHeader(code int) {
+	w.once.Do(func() {
+		w.code = code
+		w.snapshot = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *handlerResponseWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.ready:
+	default:
+		if len(w.header.Get("Content-Type")) == 0 {
+			w.header.Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.pw.Write(p)
+}
+
+func (w *handlerResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
+
+// commit sends the header when the handler returns without writing,
+// or fails the round trip when err is set.
+func (w *handlerResponseWriter) commit(err error) {
+	w.once.Do(func() {
+		w.err = err
+		w.code = http.StatusOK
+		w.snapshot = w.header.Clone()
+		close(w.ready)
+	})
+}
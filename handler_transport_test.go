@@ -0,0 +1,53 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHandlerTransport(t *testing.T) {
+	router := NewRouter()
+	router.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := _ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s %s %v %s", r.Method, r.Host, r.RequestURI, r.RemoteAddr, r.TLS != nil, b)
+	})
+	router.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	c := NewClientForHandler(router)
+
+	s, err := c.Post(context.TODO(), "https://api.example.com/info?a=1", "body").Do().String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "POST api.example.com /info?a=1 192.0.2.1:1234 true body" {
+		t.Fatal("unexpected request", s)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	resp := c.Get(ctx, "http://example.com/stream").Do()
+	if err := resp.Error(); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := resp.Response().Body.Read(buf); err != nil || string(buf) != "first" {
+		t.Fatal("unexpected chunk", string(buf), err)
+	}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := _ReadAll(resp.Response().Body); !errors.Is(err, context.Canceled) {
+		t.Fatal("want context.Canceled, got", err)
+	}
+
+	if err := c.Get(context.TODO(), "http://example.com/panic").Do().Error(); !errors.Is(err, ErrHandlerPanic) {
+		t.Fatal("want ErrHandlerPanic, got", err)
+	}
+}
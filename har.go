@@ -0,0 +1,450 @@
+package xhttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is an HTTP Archive 1.2 document.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings are in milliseconds, -1 when a phase does not apply.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+type HARConfig struct {
+	// Optional. Default value 1 MB. Longer bodies are truncated in the archive.
+	MaxBodySize int64
+
+	// RedactHeaders are recorded as "REDACTED", their cookies included.
+	// Optional. Default value the headers redacted by Curl and "Set-Cookie",
+	// an empty slice keeps all values.
+	RedactHeaders []string
+
+	// OnEntry receives an archive with the single entry of every call.
+	// Entries are accumulated for Flush only when OnEntry is nil.
+	OnEntry func(har *HAR)
+}
+
+// HARRecorder captures the traffic of a Client as an HTTP Archive.
+// An entry is complete once the response body is closed.
+//
+//	rec := xhttp.NewHARRecorder(xhttp.HARConfig{})
+//	c := xhttp.NewClient().Interceptor(rec.Interceptor)
+//	...
+//	rec.Flush(f)
+type HARRecorder struct {
+	config HARConfig
+
+	mu      sync.Mutex
+	entries []*HAREntry
+}
+
+func NewHARRecorder(config HARConfig) *HARRecorder {
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+	if config.RedactHeaders == nil {
+		config.RedactHeaders = append([]string{"Set-Cookie"}, defaultRedactHeaders...)
+	}
+	return &HARRecorder{config: config}
+}
+
+func (rec *HARRecorder) Interceptor(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	return func(req Request) (Response, error) {
+		trace, ctx := newRoundTripTrace(req.Context())
+		req.WithContext(ctx)
+		r := req.Request()
+		reqBody := rec.captureRequest(r)
+
+		resp, err := next(req)
+		if err != nil {
+			trace.finish()
+			e := rec.entry(r, reqBody, nil, nil, trace)
+			e.Comment = err.Error()
+			rec.add(e)
+			return resp, err
+		}
+		res := resp.Response()
+		resBody := newCapturedBody(res.Body, rec.config.MaxBodySize)
+		if res.Body != nil && res.Body != http.NoBody {
+			res.Body = resBody
+		}
+		OnBodyClose(res, func() {
+			trace.finish()
+			rec.add(rec.entry(r, reqBody, res, resBody, trace))
+		})
+		return resp, nil
+	}
+}
+
+// captureRequest reads up to MaxBodySize of the body with GetBody when
+// possible, taking the size from ContentLength, otherwise the body is
+// captured while it is sent.
+func (rec *HARRecorder) captureRequest(r *http.Request) *capturedBody {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if r.GetBody != nil {
+		if rc, err := r.GetBody(); err == nil {
+			if pr, ok := rc.(*progressReader); ok {
+				rc = pr.ReadCloser
+			}
+			c := newCapturedBody(rc, rec.config.MaxBodySize)
+			io.Copy(&countWriter{}, io.LimitReader(c, rec.config.MaxBodySize+1))
+			c.Close()
+			if r.ContentLength > c.n {
+				c.n = r.ContentLength
+			}
+			return c
+		}
+	}
+	c := newCapturedBody(r.Body, rec.config.MaxBodySize)
+	r.Body = c
+	return c
+}
+
+func (rec *HARRecorder) add(e *HAREntry) {
+	if rec.config.OnEntry != nil {
+		rec.config.OnEntry(newHAR([]*HAREntry{e}))
+		return
+	}
+	rec.mu.Lock()
+	rec.entries = append(rec.entries, e)
+	rec.mu.Unlock()
+}
+
+// HAR returns the accumulated entries.
+func (rec *HARRecorder) HAR() *HAR {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return newHAR(append([]*HAREntry(nil), rec.entries...))
+}
+
+// Flush writes the accumulated entries to w and clears them.
+func (rec *HARRecorder) Flush(w io.Writer) error {
+	rec.mu.Lock()
+	entries := rec.entries
+	rec.entries = nil
+	rec.mu.Unlock()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newHAR(entries))
+}
+
+func newHAR(entries []*HAREntry) *HAR {
+	if entries == nil {
+		entries = []*HAREntry{}
+	}
+	return &HAR{Log: HARLog{Version: "1.2", Creator: HARCreator{Name: "xhttp", Version: "1.0"}, Entries: entries}}
+}
+
+func (rec *HARRecorder) entry(r *http.Request, reqBody *capturedBody, res *http.Response, resBody *capturedBody, t *roundTripTrace) *HAREntry {
+	if reqBody != nil {
+		// the transport may still be sending the body
+		reqBody.wait()
+	}
+	redact := rec.config.RedactHeaders
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := &HAREntry{
+		StartedDateTime: t.start.Format(time.RFC3339Nano),
+		Time:            harMillis(since(t.start, t.end)),
+		ServerIPAddress: t.remoteAddr,
+	}
+	if i := strings.LastIndex(e.ServerIPAddress, ":"); i >= 0 {
+		e.ServerIPAddress = strings.Trim(e.ServerIPAddress[:i], "[]")
+	}
+
+	e.Request = HARRequest{
+		Method:      r.Method,
+		URL:         r.URL.String(),
+		HTTPVersion: r.Proto,
+		Cookies:     harCookies(r.Cookies(), containsFold(redact, "Cookie")),
+		Headers:     harHeaders(r.Header, redact),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+	if len(e.Request.HTTPVersion) == 0 {
+		e.Request.HTTPVersion = "HTTP/1.1"
+	}
+	for k, vs := range r.URL.Query() {
+		for _, v := range vs {
+			e.Request.QueryString = append(e.Request.QueryString, HARNameValue{Name: k, Value: v})
+		}
+	}
+	sort.Slice(e.Request.QueryString, func(i, j int) bool { return e.Request.QueryString[i].Name < e.Request.QueryString[j].Name })
+	if reqBody != nil {
+		text, encoding := reqBody.text()
+		e.Request.BodySize = reqBody.n
+		e.Request.PostData = &HARPostData{MimeType: r.Header.Get("Content-Type"), Text: text}
+		if len(encoding) > 0 {
+			e.Request.PostData.Text = ""
+			e.Request.PostData.Comment = "binary body omitted"
+		} else if reqBody.truncated() {
+			e.Request.PostData.Comment = "truncated"
+		}
+	}
+
+	e.Response = HARResponse{Cookies: []HARCookie{}, Headers: []HARNameValue{}, HeadersSize: -1, BodySize: -1}
+	if res != nil {
+		e.Response.Status = res.StatusCode
+		e.Response.StatusText = http.StatusText(res.StatusCode)
+		e.Response.HTTPVersion = res.Proto
+		e.Response.Cookies = harCookies(res.Cookies(), containsFold(redact, "Set-Cookie"))
+		e.Response.Headers = harHeaders(res.Header, redact)
+		e.Response.RedirectURL = res.Header.Get("Location")
+		e.Response.Content.MimeType = res.Header.Get("Content-Type")
+		if resBody != nil {
+			e.Response.BodySize = resBody.n
+			e.Response.Content.Size = resBody.n
+			e.Response.Content.Text, e.Response.Content.Encoding = resBody.text()
+			if resBody.truncated() {
+				e.Response.Content.Comment = "truncated"
+			}
+		}
+	}
+
+	dns := since(t.dnsStart, t.dnsDone)
+	connectDone := t.connectDone
+	if !t.tlsDone.IsZero() {
+		connectDone = t.tlsDone
+	}
+	connect := since(t.connectStart, connectDone)
+	blocked := since(t.start, t.gotConn)
+	if blocked >= 0 {
+		if dns > 0 {
+			blocked -= dns
+		}
+		if connect > 0 {
+			blocked -= connect
+		}
+		if blocked < 0 {
+			blocked = 0
+		}
+	}
+	e.Timings = HARTimings{
+		Blocked: harMillis(blocked),
+		DNS:     harMillis(dns),
+		Connect: harMillis(connect),
+		SSL:     harMillis(since(t.tlsStart, t.tlsDone)),
+		Send:    harMillis(since(t.gotConn, t.wroteRequest)),
+		Wait:    harMillis(since(t.wroteRequest, t.firstByte)),
+		Receive: harMillis(since(t.firstByte, t.end)),
+	}
+	// send, wait and receive are required
+	for _, p := range []*float64{&e.Timings.Send, &e.Timings.Wait, &e.Timings.Receive} {
+		if *p < 0 {
+			*p = 0
+		}
+	}
+	return e
+}
+
+func harMillis(d time.Duration) float64 {
+	if d < 0 {
+		return -1
+	}
+	return float64(d) / float64(time.Millisecond)
+}
+
+func harHeaders(h http.Header, redact []string) []HARNameValue {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	nvs := []HARNameValue{}
+	for _, k := range keys {
+		for _, v := range h[k] {
+			if containsFold(redact, k) {
+				v = redacted
+			}
+			nvs = append(nvs, HARNameValue{Name: k, Value: v})
+		}
+	}
+	return nvs
+}
+
+func harCookies(cookies []*http.Cookie, redact bool) []HARCookie {
+	hcs := []HARCookie{}
+	for _, c := range cookies {
+		hc := HARCookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if redact {
+			hc.Value = redacted
+		}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.Format(time.RFC3339)
+		}
+		hcs = append(hcs, hc)
+	}
+	return hcs
+}
+
+// capturedBody keeps the first limit bytes read and counts the rest.
+type capturedBody struct {
+	io.ReadCloser
+	limit int64
+	buf   bytes.Buffer
+	n     int64
+
+	mu      sync.Mutex
+	started bool
+	once    sync.Once
+	done    chan struct{}
+}
+
+func newCapturedBody(rc io.ReadCloser, limit int64) *capturedBody {
+	return &capturedBody{ReadCloser: rc, limit: limit, done: make(chan struct{})}
+}
+
+func (b *capturedBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	b.started = true
+	b.mu.Unlock()
+	n, err := b.ReadCloser.Read(p)
+	if room := b.limit - int64(b.buf.Len()); room > 0 {
+		if int64(n) < room {
+			room = int64(n)
+		}
+		b.buf.Write(p[:room])
+	}
+	b.n += int64(n)
+	if err != nil {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *capturedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *capturedBody) finish() {
+	b.once.Do(func() { close(b.done) })
+}
+
+// wait returns once a body that is being read is read to the end or
+// closed, a body nobody started to read is not waited for.
+func (b *capturedBody) wait() {
+	b.mu.Lock()
+	started := b.started
+	b.mu.Unlock()
+	if started {
+		<-b.done
+	}
+}
+
+func (b *capturedBody) truncated() bool {
+	return b.n > int64(b.buf.Len())
+}
+func (b *capturedBody) text() (string, string) {
+	p := b.buf.Bytes()
+	if b.truncated() {
+		// the cut may split a rune
+		for i := 0; i < utf8.UTFMax-1 && len(p) > 0 && !utf8.Valid(p); i++ {
+			p = p[:len(p)-1]
+		}
+	}
+	if utf8.Valid(p) {
+		return string(p), ""
+	}
+	return base64.StdEncoding.EncodeToString(b.buf.Bytes()), "base64"
+}
@@ -0,0 +1,154 @@
+package xhttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHARRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+		w.Header().Set("Content-Type", "text/plain")
+		b, _ := _ReadAll(r.Body)
+		w.Write(bytes.Repeat(b, 10))
+	}))
+	defer srv.Close()
+
+	rec := NewHARRecorder(HARConfig{MaxBodySize: 8})
+	c := NewClient().Interceptor(rec.Interceptor)
+	if s, _ := c.Post(context.TODO(), srv.URL+"/x?q=1", "abc").Do().String(); len(s) != 30 {
+		t.Fatal("unexpected body", s)
+	}
+	c.Post(context.TODO(), srv.URL+"/y", strings.NewReader("hello")).Do().Bytes()
+
+	var buf bytes.Buffer
+	if err := rec.Flush(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var har HAR
+	if err := json.Unmarshal(buf.Bytes(), &har); err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Fatal("unexpected archive", buf.String())
+	}
+	e := har.Log.Entries[0]
+	if e.Request.Method != "POST" || e.Request.PostData.Text != "abc" || len(e.Request.QueryString) != 1 {
+		t.Fatal("unexpected request", e.Request)
+	}
+	if e.Response.Status != 200 || e.Response.Content.Text != "abcabcab" || e.Response.Content.Size != 30 ||
+		e.Response.Content.Comment != "truncated" || len(e.Response.Cookies) != 1 {
+		t.Fatal("unexpected response", e.Response)
+	}
+	if e.Time <= 0 || e.Timings.Wait < 0 || e.ServerIPAddress != "127.0.0.1" {
+		t.Fatal("unexpected timings", e.Time, e.Timings, e.ServerIPAddress)
+	}
+	if e := har.Log.Entries[1]; e.Request.PostData.Text != "hello" || e.Request.BodySize != 5 {
+		t.Fatal("streamed body not captured", e.Request)
+	}
+	if len(rec.HAR().Log.Entries) != 0 {
+		t.Fatal("entries not flushed")
+	}
+
+	var entries int
+	rec = NewHARRecorder(HARConfig{OnEntry: func(har *HAR) { entries += len(har.Log.Entries) }})
+	NewClient().Interceptor(rec.Interceptor).Get(context.TODO(), srv.URL).Do().Bytes()
+	if entries != 1 {
+		t.Fatal("want 1 entry, got", entries)
+	}
+
+	// a rewindable body is read up to MaxBodySize, without upload progress
+	var progress int
+	rec = NewHARRecorder(HARConfig{MaxBodySize: 8})
+	NewClient().Interceptor(rec.Interceptor).Post(context.TODO(), srv.URL, strings.Repeat("x", 100)).
+		UploadProgress(func(int64, int64) { progress++ }).Do().Bytes()
+	e = rec.HAR().Log.Entries[0]
+	if e.Request.BodySize != 100 || e.Request.PostData.Text != "xxxxxxxx" || e.Request.PostData.Comment != "truncated" {
+		t.Fatal("unexpected request", e.Request)
+	}
+	if progress == 0 || progress > 2 {
+		t.Fatal("unexpected progress callbacks", progress)
+	}
+}
+
+type slowReader struct {
+	chunks []string
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	// longer than the transport waits for the request to be written
+	time.Sleep(30 * time.Millisecond)
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestHARRecorderRedactAndEarlyResponse(t *testing.T) {
+	// replies before the request body is sent
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					r, err := http.ReadRequest(br)
+					if err != nil {
+						return
+					}
+					io.WriteString(conn, "HTTP/1.1 200 OK\r\nSet-Cookie: sid=secret\r\nContent-Length: 2\r\n\r\nok")
+					_ReadAll(r.Body)
+				}
+			}()
+		}
+	}()
+	u := "http://" + ln.Addr().String()
+
+	rec := NewHARRecorder(HARConfig{})
+	c := NewClient().Interceptor(rec.Interceptor)
+	req := c.Post(context.TODO(), u, &slowReader{chunks: []string{"a", "b", "c"}}).
+		Bearer("token").AddHeader("Cookie", "sid=secret")
+	if _, err := req.Do().Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	c.Get(context.TODO(), u).Do().Bytes()
+	har := rec.HAR()
+	if len(har.Log.Entries) != 2 {
+		t.Fatal("want 2 entries, got", len(har.Log.Entries))
+	}
+	e := har.Log.Entries[0]
+	// the transport gives up sending the body once the response is read
+	if text := e.Request.PostData.Text; !strings.HasPrefix("abc", text) || e.Request.BodySize != int64(len(text)) {
+		t.Fatal("body copied while it was sent", text, e.Request.BodySize)
+	}
+	b, _ := json.Marshal(har)
+	if strings.Contains(string(b), "secret") || strings.Contains(string(b), "token") {
+		t.Fatal("credentials recorded", string(b))
+	}
+
+	rec = NewHARRecorder(HARConfig{RedactHeaders: []string{}})
+	NewClient().Interceptor(rec.Interceptor).Get(context.TODO(), u).Bearer("token").Do().Bytes()
+	if e := rec.HAR().Log.Entries[0]; e.Request.Headers[0].Value != "Bearer token" || e.Response.Cookies[0].Value != "secret" {
+		t.Fatal("values redacted", e.Request.Headers, e.Response.Cookies)
+	}
+}
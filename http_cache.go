@@ -0,0 +1,477 @@
+package xhttp
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// XFromCache is set on responses served from the client cache.
+const XFromCache = "X-From-Cache"
+
+func IsFromCache(res Response) bool {
+	if res == nil || res.Response() == nil {
+		return false
+	}
+	return res.Response().Header.Get(XFromCache) == "1"
+}
+
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+type HTTPCacheConfig struct {
+	Store CacheStore
+
+	// Optional. Default value the request url.
+	KeyFunc func(req *http.Request) string
+
+	// Optional. Default value 24 hours, caps the heuristic freshness
+	// derived from Last-Modified.
+	MaxHeuristicAge time.Duration
+
+	// Optional. Default value 1 MB. Larger responses are not cached.
+	MaxEntrySize int64
+
+	Now func() time.Time
+}
+
+func (c Client) HTTPCache(store CacheStore) Client {
+	return c.Interceptor(HTTPCacheInterceptor(HTTPCacheConfig{Store: store}))
+}
+
+// HTTPCacheInterceptor is a private cache following RFC 9111. Fresh
+// responses are served without a request, stale ones are revalidated
+// with If-None-Match and If-Modified-Since.
+func HTTPCacheInterceptor(config HTTPCacheConfig) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	if config.Store == nil {
+		config.Store = NewMemoryCacheStore(1024)
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = func(req *http.Request) string { return req.URL.String() }
+	}
+	if config.MaxHeuristicAge <= 0 {
+		config.MaxHeuristicAge = 24 * time.Hour
+	}
+	if config.MaxEntrySize <= 0 {
+		config.MaxEntrySize = 1 << 20
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			r := req.Request()
+			key := config.KeyFunc(r)
+			if r.Method != http.MethodGet {
+				resp, err := next(req)
+				if err == nil && r.Method != http.MethodHead && r.Method != http.MethodOptions && r.Method != http.MethodTrace {
+					if res := resp.Response(); res != nil && res.StatusCode < 400 {
+						config.Store.Delete(key)
+					}
+				}
+				return resp, err
+			}
+			reqCC := parseCacheControl(r.Header)
+			if _, ok := reqCC["no-store"]; ok {
+				return next(req)
+			}
+
+			entry := loadCacheEntry(config.Store, key, r)
+			if entry == nil {
+				if _, ok := reqCC["only-if-cached"]; ok {
+					return NewResponse(req, cacheResponse(r, http.StatusGatewayTimeout), nil), nil
+				}
+				return storeResponse(config, key, next, req, config.Now())
+			}
+
+			cached, err := entry.response(r)
+			if err != nil {
+				config.Store.Delete(key)
+				return next(req)
+			}
+			now := config.Now()
+			resCC := parseCacheControl(cached.Header)
+			if entry.fresh(cached, reqCC, resCC, now, config.MaxHeuristicAge) {
+				cached.Header.Set("Age", strconv.FormatInt(int64(entry.age(cached, now).Seconds()), 10))
+				cached.Header.Set(XFromCache, "1")
+				return NewResponse(req, cached, nil), nil
+			}
+			if _, ok := reqCC["only-if-cached"]; ok {
+				return NewResponse(req, cacheResponse(r, http.StatusGatewayTimeout), nil), nil
+			}
+
+			// a 304 answers the validators of the caller, not the cached response
+			conditional := len(r.Header.Get("If-None-Match")) > 0 || len(r.Header.Get("If-Modified-Since")) > 0
+			etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+			if len(etag) == 0 && len(lastModified) == 0 {
+				return storeResponse(config, key, next, req, now)
+			}
+			if len(etag) > 0 && len(r.Header.Get("If-None-Match")) == 0 {
+				r.Header.Set("If-None-Match", etag)
+				defer r.Header.Del("If-None-Match")
+			}
+			if len(lastModified) > 0 && len(r.Header.Get("If-Modified-Since")) == 0 {
+				r.Header.Set("If-Modified-Since", lastModified)
+				defer r.Header.Del("If-Modified-Since")
+			}
+			resp, err := next(req)
+			if err != nil || resp.Response() == nil || resp.Response().StatusCode != http.StatusNotModified {
+				if err == nil {
+					err = storeEntry(config, key, req, resp, now)
+				}
+				return resp, err
+			}
+			if conditional {
+				return resp, nil
+			}
+			res := resp.Response()
+			res.Body.Close()
+			for k, vs := range res.Header {
+				if k == "Content-Length" {
+					continue
+				}
+				cached.Header[k] = vs
+			}
+			cached.Header.Del(XFromCache)
+			if b, err := entry.update(cached, now); err == nil {
+				config.Store.Set(key, b)
+			}
+			cached.Header.Set(XFromCache, "1")
+			return NewResponse(req, cached, nil), nil
+		}
+	}
+}
+
+func storeResponse(config HTTPCacheConfig, key string, next func(req Request) (Response, error), req Request, requestAt time.Time) (Response, error) {
+	resp, err := next(req)
+	if err != nil {
+		return resp, err
+	}
+	return resp, storeEntry(config, key, req, resp, requestAt)
+}
+
+var cacheableStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+func storeEntry(config HTTPCacheConfig, key string, req Request, resp Response, requestAt time.Time) error {
+	res := resp.Response()
+	if res == nil || !cacheableStatus[res.StatusCode] {
+		return nil
+	}
+	resCC := parseCacheControl(res.Header)
+	if _, ok := resCC["no-store"]; ok {
+		return nil
+	}
+	_, hasMaxAge := resCC["max-age"]
+	if !hasMaxAge && len(res.Header.Get("Expires")) == 0 &&
+		len(res.Header.Get("ETag")) == 0 && len(res.Header.Get("Last-Modified")) == 0 {
+		return nil
+	}
+	vary := map[string]string{}
+	for _, name := range headerTokens(res.Header, "Vary") {
+		if name == "*" {
+			return nil
+		}
+		vary[http.CanonicalHeaderKey(name)] = req.Request().Header.Get(name)
+	}
+	if res.ContentLength > config.MaxEntrySize {
+		return nil
+	}
+	body, err := _ReadAll(io.LimitReader(res.Body, config.MaxEntrySize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > config.MaxEntrySize {
+		// too large, hand the body on unread
+		res.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), res.Body), Closer: res.Body}
+		return nil
+	}
+	res.Body.Close()
+	res.Body = _NopCloser(bytes.NewReader(body))
+	dump, err := httputil.DumpResponse(res, true)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(&cacheEntry{Vary: vary, RequestAt: requestAt, ResponseAt: config.Now(), Response: dump})
+	if err == nil {
+		config.Store.Set(key, b)
+	}
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func cacheResponse(req *http.Request, code int) *http.Response {
+	return &http.Response{
+		Status:     strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode: code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{XFromCache: []string{"1"}},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+type cacheEntry struct {
+	Vary       map[string]string `json:"vary,omitempty"`
+	RequestAt  time.Time         `json:"request_at"`
+	ResponseAt time.Time         `json:"response_at"`
+	Response   []byte            `json:"response"`
+}
+
+func loadCacheEntry(store CacheStore, key string, req *http.Request) *cacheEntry {
+	b, ok := store.Get(key)
+	if !ok {
+		return nil
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		store.Delete(key)
+		return nil
+	}
+	for name, v := range e.Vary {
+		if req.Header.Get(name) != v {
+			return nil
+		}
+	}
+	return &e
+}
+
+func (e *cacheEntry) response(req *http.Request) (*http.Response, error) {
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(e.Response)), req)
+}
+
+func (e *cacheEntry) update(res *http.Response, now time.Time) ([]byte, error) {
+	dump, err := httputil.DumpResponse(res, true)
+	if err != nil {
+		return nil, err
+	}
+	e.RequestAt, e.ResponseAt, e.Response = now, now, dump
+	return json.Marshal(e)
+}
+
+// age is the current age of the response, RFC 9111 section 4.2.3.
+func (e *cacheEntry) age(res *http.Response, now time.Time) time.Duration {
+	var apparentAge time.Duration
+	if date, err := http.ParseTime(res.Header.Get("Date")); err == nil {
+		if d := e.ResponseAt.Sub(date); d > 0 {
+			apparentAge = d
+		}
+	}
+	ageValue, _ := strconv.ParseInt(res.Header.Get("Age"), 10, 64)
+	correctedAge := time.Duration(ageValue)*time.Second + e.ResponseAt.Sub(e.RequestAt)
+	if apparentAge > correctedAge {
+		correctedAge = apparentAge
+	}
+	return correctedAge + now.Sub(e.ResponseAt)
+}
+
+// lifetime is the freshness lifetime of the response, RFC 9111 section 4.2.1.
+func (e *cacheEntry) lifetime(res *http.Response, resCC map[string]string, maxHeuristicAge time.Duration) time.Duration {
+	if v, ok := resCC["max-age"]; ok {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return time.Duration(n) * time.Second
+	}
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		date = e.ResponseAt
+	}
+	if expires := res.Header.Get("Expires"); len(expires) > 0 {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(date)
+	}
+	if lm, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil && date.After(lm) {
+		d := date.Sub(lm) / 10
+		if d > maxHeuristicAge {
+			d = maxHeuristicAge
+		}
+		return d
+	}
+	return 0
+}
+
+func (e *cacheEntry) fresh(res *http.Response, reqCC map[string]string, resCC map[string]string, now time.Time, maxHeuristicAge time.Duration) bool {
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+	if _, ok := resCC["no-cache"]; ok {
+		return false
+	}
+	if strings.Contains(strings.ToLower(res.Header.Get("Pragma")), "no-cache") && len(resCC) == 0 {
+		return false
+	}
+	lifetime := e.lifetime(res, resCC, maxHeuristicAge)
+	age := e.age(res, now)
+	if v, ok := reqCC["max-age"]; ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && age > time.Duration(n)*time.Second {
+			return false
+		}
+	}
+	if v, ok := reqCC["min-fresh"]; ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			age += time.Duration(n) * time.Second
+		}
+	}
+	if age < lifetime {
+		return true
+	}
+	if _, ok := resCC["must-revalidate"]; ok {
+		return false
+	}
+	if v, ok := reqCC["max-stale"]; ok {
+		if len(v) == 0 {
+			return true
+		}
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && age-lifetime <= time.Duration(n)*time.Second {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCacheControl(h http.Header) map[string]string {
+	cc := map[string]string{}
+	for _, d := range headerTokens(h, "Cache-Control") {
+		k, v := d, ""
+		if p := strings.Index(d, "="); p >= 0 {
+			k, v = d[:p], strings.Trim(d[p+1:], `"`)
+		}
+		cc[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	return cc
+}
+
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); len(t) > 0 {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+type memoryCacheStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCacheStore is a LRU store holding at most maxEntries responses.
+func NewMemoryCacheStore(maxEntries int) CacheStore {
+	return &memoryCacheStore{maxEntries: maxEntries, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (s *memoryCacheStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.ll.MoveToFront(e)
+		return e.Value.(*memoryCacheItem).value, true
+	}
+	return nil, false
+}
+
+func (s *memoryCacheStore) Set(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.ll.MoveToFront(e)
+		e.Value.(*memoryCacheItem).value = value
+		return
+	}
+	s.items[key] = s.ll.PushFront(&memoryCacheItem{key: key, value: value})
+	for s.maxEntries > 0 && s.ll.Len() > s.maxEntries {
+		e := s.ll.Back()
+		s.ll.Remove(e)
+		delete(s.items, e.Value.(*memoryCacheItem).key)
+	}
+}
+
+func (s *memoryCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.ll.Remove(e)
+		delete(s.items, key)
+	}
+}
+
+type diskCacheStore struct {
+	dir string
+}
+
+// NewDiskCacheStore keeps one file per response in dir.
+func NewDiskCacheStore(dir string) CacheStore {
+	return &diskCacheStore{dir: dir}
+}
+
+func (s *diskCacheStore) file(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+func (s *diskCacheStore) Get(key string) ([]byte, bool) {
+	b, err := _ReadFile(s.file(key))
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+func (s *diskCacheStore) Set(key string, value []byte) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return
+	}
+	f, err := _CreateTemp(s.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(value)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.file(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+func (s *diskCacheStore) Delete(key string) {
+	os.Remove(s.file(key))
+}
@@ -0,0 +1,122 @@
+package xhttp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHTTPCache(t *testing.T) {
+	var hits, notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			WriteString(w, http.StatusOK, "", "fresh "+r.Header.Get("Accept-Language"))
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			WriteString(w, http.StatusOK, "", "etag")
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+			WriteString(w, http.StatusOK, "", "nostore")
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := _MkdirTemp("", "xhttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, store := range []CacheStore{NewMemoryCacheStore(16), NewDiskCacheStore(dir)} {
+		atomic.StoreInt32(&hits, 0)
+		atomic.StoreInt32(&notModified, 0)
+		c := NewClient().HTTPCache(store)
+		get := func(path string, lang string) (string, bool) {
+			t.Helper()
+			resp := c.Get(context.TODO(), srv.URL+path).SetHeader("Accept-Language", lang).Do()
+			s, err := resp.String()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s, IsFromCache(resp)
+		}
+
+		if s, cached := get("/fresh", "en"); s != "fresh en" || cached {
+			t.Fatal(s, cached)
+		}
+		if s, cached := get("/fresh", "en"); s != "fresh en" || !cached {
+			t.Fatal(s, cached)
+		}
+		if s, cached := get("/fresh", "fr"); s != "fresh fr" || cached {
+			t.Fatal("want vary miss", s, cached)
+		}
+		if s, cached := get("/etag", ""); s != "etag" || cached {
+			t.Fatal(s, cached)
+		}
+		if s, cached := get("/etag", ""); s != "etag" || !cached || notModified != 1 {
+			t.Fatal("want revalidated", s, cached, notModified)
+		}
+		get("/nostore", "")
+		if _, cached := get("/nostore", ""); cached {
+			t.Fatal("want no-store to bypass the cache")
+		}
+		if hits != 6 {
+			t.Fatal("want 6 hits, got", hits)
+		}
+	}
+}
+
+func TestHTTPCacheLargeAndConditional(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		switch r.URL.Path {
+		case "/large":
+			w.Write(bytes.Repeat([]byte("x"), 64))
+			w.(http.Flusher).Flush()
+			w.Write(bytes.Repeat([]byte("x"), 64))
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v2"`)
+			if r.Header.Get("If-None-Match") == `"v1"` || r.Header.Get("If-None-Match") == `"v2"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			WriteString(w, http.StatusOK, "", "etag")
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient().Interceptor(HTTPCacheInterceptor(HTTPCacheConfig{MaxEntrySize: 100}))
+	for i := 0; i < 2; i++ {
+		resp := c.Get(context.TODO(), srv.URL+"/large").Do()
+		if b, err := resp.Bytes(); err != nil || len(b) != 128 || IsFromCache(resp) {
+			t.Fatal("large response cached", len(b), err)
+		}
+	}
+
+	c.Get(context.TODO(), srv.URL+"/etag").Do().Bytes()
+	resp := c.Get(context.TODO(), srv.URL+"/etag").SetHeader("If-None-Match", `"v1"`).Do()
+	if resp.Response().StatusCode != http.StatusNotModified || IsFromCache(resp) {
+		t.Fatal("want 304 of the caller validator, got", resp.Response().StatusCode)
+	}
+	resp.Response().Body.Close()
+	if hits != 4 {
+		t.Fatal("want 4 hits, got", hits)
+	}
+}
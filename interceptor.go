@@ -1,17 +1,12 @@
 package xhttp
 
-import (
-	"errors"
-	"strconv"
-)
-
 func WhetherStatusCode(statusCode int) func(r Response) error {
 	return func(r Response) error {
 		res := r.Response()
 		if res.StatusCode == statusCode {
 			return nil
 		}
-		return errors.New("wrong status code: " + strconv.FormatInt(int64(res.StatusCode), 10))
+		return NewStatusError(res, nil)
 	}
 }
 
@@ -23,6 +18,6 @@ func WhetherStatusCodes(statusCodes ...int) func(r Response) error {
 				return nil
 			}
 		}
-		return errors.New("wrong status code: " + strconv.FormatInt(int64(res.StatusCode), 10))
+		return NewStatusError(res, nil)
 	}
 }
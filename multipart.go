@@ -0,0 +1,195 @@
+package xhttp
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"sync"
+)
+
+// formPart is a field or a file of a multipart/form-data body. File
+// parts are opened only while the body is streamed, so nothing is
+// buffered in memory.
+type formPart struct {
+	name        string
+	value       string
+	filename    string
+	contentType string
+	isFile      bool
+
+	// open returns the content of a file part, it may be called again
+	// to rewind the body when reopenable is true.
+	open       func() (io.ReadCloser, error)
+	reopenable bool
+
+	// size of a file part, -1 if unknown.
+	size int64
+
+	// path of a part added by File.
+	path string
+}
+
+var errPartConsumed = errors.New("multipart reader part can not be read twice")
+
+func readerPart(name string, filename string, rd io.Reader, contentType string) *formPart {
+	p := &formPart{name: name, filename: filename, contentType: contentType, isFile: true, size: -1}
+	switch b := rd.(type) {
+	case interface{ Len() int }:
+		p.size = int64(b.Len())
+	case io.Seeker:
+		if cur, err := b.Seek(0, io.SeekCurrent); err == nil {
+			if end, err := b.Seek(0, io.SeekEnd); err == nil {
+				p.size = end - cur
+			}
+			b.Seek(cur, io.SeekStart)
+		}
+	}
+	var once sync.Once
+	p.open = func() (rc io.ReadCloser, err error) {
+		err = errPartConsumed
+		once.Do(func() {
+			rc, err = _NopCloser(rd), nil
+		})
+		return rc, err
+	}
+	return p
+}
+
+type multipartBody struct {
+	parts    []*formPart
+	boundary string
+}
+
+func newMultipartBody(parts []*formPart) *multipartBody {
+	return &multipartBody{parts: parts, boundary: multipart.NewWriter(nil).Boundary()}
+}
+
+func (b *multipartBody) ContentType() string {
+	return "multipart/form-data; boundary=" + b.boundary
+}
+
+func (b *multipartBody) Reopenable() bool {
+	for _, p := range b.parts {
+		if p.isFile && !p.reopenable {
+			return false
+		}
+	}
+	return true
+}
+
+// ContentLength computes the body length without reading any file,
+// returning -1 when the size of a part is unknown.
+func (b *multipartBody) ContentLength() int64 {
+	var total int64
+	cw := &countWriter{}
+	mw := multipart.NewWriter(cw)
+	mw.SetBoundary(b.boundary)
+	for _, p := range b.parts {
+		if !p.isFile {
+			mw.WriteField(p.name, p.value)
+			continue
+		}
+		if p.size < 0 {
+			return -1
+		}
+		mw.CreatePart(filePartHeader(p.name, p.filename, p.contentType))
+		total += p.size
+	}
+	mw.Close()
+	return total + cw.n
+}
+
+func (b *multipartBody) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	mw := multipart.NewWriter(cw)
+	mw.SetBoundary(b.boundary)
+	for _, p := range b.parts {
+		if !p.isFile {
+			if err := mw.WriteField(p.name, p.value); err != nil {
+				return cw.n, err
+			}
+			continue
+		}
+		pw, err := mw.CreatePart(filePartHeader(p.name, p.filename, p.contentType))
+		if err != nil {
+			return cw.n, err
+		}
+		rc, err := p.open()
+		if err != nil {
+			return cw.n, err
+		}
+		_, err = io.Copy(pw, rc)
+		rc.Close()
+		if err != nil {
+			return cw.n, err
+		}
+	}
+	err := mw.Close()
+	return cw.n, err
+}
+
+// Reader streams the body through a pipe, the writer only starts on the first read.
+func (b *multipartBody) Reader() io.ReadCloser {
+	return &pipeBody{writeTo: b.WriteTo}
+}
+
+type pipeBody struct {
+	writeTo func(w io.Writer) (int64, error)
+	once    sync.Once
+	mu      sync.Mutex
+	pr      *io.PipeReader
+	closed  bool
+}
+
+func (b *pipeBody) reader() *io.PipeReader {
+	b.once.Do(func() {
+		pr, pw := io.Pipe()
+		b.mu.Lock()
+		b.pr = pr
+		closed := b.closed
+		b.mu.Unlock()
+		if closed {
+			pr.Close()
+		}
+		go func() {
+			_, err := b.writeTo(pw)
+			pw.CloseWithError(err)
+		}()
+	})
+	return b.pr
+}
+
+func (b *pipeBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return 0, io.ErrClosedPipe
+	}
+	return b.reader().Read(p)
+}
+
+func (b *pipeBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	if b.pr != nil {
+		return b.pr.Close()
+	}
+	return nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (n int, err error) {
+	if cw.w == nil {
+		n = len(p)
+	} else {
+		n, err = cw.w.Write(p)
+	}
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,291 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoRefreshToken = errors.New("oauth2: token has no refresh token")
+
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+
+	// Expiry is computed from ExpiresIn when the token is received,
+	// the zero value means the token never expires.
+	Expiry time.Time `json:"expiry,omitempty"`
+}
+
+// Type returns the token type, defaulting to "Bearer".
+func (t *Token) Type() string {
+	if len(t.TokenType) == 0 || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer"
+	}
+	return t.TokenType
+}
+
+func (t *Token) expired(now time.Time, delta time.Duration) bool {
+	return !t.Expiry.IsZero() && !now.Add(delta).Before(t.Expiry)
+}
+
+func (t *Token) Valid() bool {
+	return t != nil && len(t.AccessToken) > 0 && !t.expired(time.Now(), 0)
+}
+
+// OAuth2Error is the error response of a token endpoint, RFC 6749 section 5.2.
+type OAuth2Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	URI         string `json:"error_uri,omitempty"`
+}
+
+func (e *OAuth2Error) Error() string {
+	if len(e.Description) > 0 {
+		return "oauth2: " + e.Code + ": " + e.Description
+	}
+	return "oauth2: " + e.Code
+}
+
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// StaticTokenSource always returns t.
+func StaticTokenSource(t *Token) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (*Token, error) { return t, nil })
+}
+
+type TokenEndpoint struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+
+	// AuthInParams sends the client credentials in the form body
+	// instead of the Authorization header.
+	AuthInParams bool
+
+	// Optional. Default value NewClient().
+	Client *Client
+}
+
+// Exchange posts params to the token endpoint and decodes the token.
+func (e TokenEndpoint) Exchange(ctx context.Context, params url.Values) (*Token, error) {
+	c := e.Client
+	if c == nil {
+		c = NewClient().Ptr()
+	}
+	req := c.Post(ctx, e.TokenURL, nil)
+	req.SetHeader("Accept", "application/json")
+	if e.AuthInParams {
+		params.Set("client_id", e.ClientID)
+		if len(e.ClientSecret) > 0 {
+			params.Set("client_secret", e.ClientSecret)
+		}
+	} else if req.Request() != nil {
+		req.Request().SetBasicAuth(url.QueryEscape(e.ClientID), url.QueryEscape(e.ClientSecret))
+	}
+	for k, vs := range params {
+		for _, v := range vs {
+			req.Field(k, v)
+		}
+	}
+	var tok Token
+	if err := req.Do().DecodeResult(&tok, &OAuth2Error{}, JSON); err != nil {
+		return nil, err
+	}
+	if len(tok.AccessToken) == 0 {
+		return nil, errors.New("oauth2: server response missing access_token")
+	}
+	if tok.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+	return &tok, nil
+}
+
+type ClientCredentialsConfig struct {
+	TokenEndpoint
+
+	Scopes []string
+
+	EndpointParams url.Values
+}
+
+// ClientCredentials fetches a new token on every call, it is usually
+// wrapped by ReuseTokenSource.
+func ClientCredentials(config ClientCredentialsConfig) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		params := url.Values{"grant_type": {"client_credentials"}}
+		if len(config.Scopes) > 0 {
+			params.Set("scope", strings.Join(config.Scopes, " "))
+		}
+		for k, vs := range config.EndpointParams {
+			params[k] = append([]string(nil), vs...)
+		}
+		return config.Exchange(ctx, params)
+	})
+}
+
+// RefreshTokenSource exchanges the refresh token of t for new tokens,
+// keeping the latest refresh token when the server rotates it.
+func RefreshTokenSource(endpoint TokenEndpoint, t *Token) TokenSource {
+	var mu sync.Mutex
+	refreshToken := t.RefreshToken
+	return TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		mu.Lock()
+		rt := refreshToken
+		mu.Unlock()
+		if len(rt) == 0 {
+			return nil, ErrNoRefreshToken
+		}
+		tok, err := endpoint.Exchange(ctx, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {rt}})
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		if len(tok.RefreshToken) > 0 {
+			refreshToken = tok.RefreshToken
+		} else {
+			tok.RefreshToken = refreshToken
+		}
+		mu.Unlock()
+		return tok, nil
+	})
+}
+
+type tokenCall struct {
+	done chan struct{}
+	tok  *Token
+	err  error
+}
+
+// CachedTokenSource caches the token of its source until shortly before
+// it expires. Concurrent callers share a single fetch.
+type CachedTokenSource struct {
+	src          TokenSource
+	expiryDelta  time.Duration
+	fetchTimeout time.Duration
+
+	mu   sync.Mutex
+	tok  *Token
+	call *tokenCall
+}
+
+// ReuseTokenSource caches tokens of src, refreshing them expiryDelta before
+// they expire. Optional expiryDelta defaults to 10 seconds.
+func ReuseTokenSource(src TokenSource, expiryDelta ...time.Duration) *CachedTokenSource {
+	s := &CachedTokenSource{src: src, expiryDelta: 10 * time.Second, fetchTimeout: 30 * time.Second}
+	if len(expiryDelta) > 0 {
+		s.expiryDelta = expiryDelta[0]
+	}
+	return s
+}
+
+// FetchTimeout bounds the shared fetch of a token, 30 seconds by default,
+// so a hanging token endpoint does not block later callers for good.
+func (s *CachedTokenSource) FetchTimeout(d time.Duration) *CachedTokenSource {
+	s.fetchTimeout = d
+	return s
+}
+
+func (s *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	if s.tok != nil && !s.tok.expired(time.Now(), s.expiryDelta) {
+		tok := s.tok
+		s.mu.Unlock()
+		return tok, nil
+	}
+	call := s.call
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		s.call = call
+		// the fetch is shared, so it must not be canceled by a single caller
+		go s.fetch(call)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.tok, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *CachedTokenSource) fetch(call *tokenCall) {
+	ctx := context.Background()
+	if s.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.fetchTimeout)
+		defer cancel()
+	}
+	call.tok, call.err = s.src.Token(ctx)
+	s.mu.Lock()
+	if call.err == nil {
+		s.tok = call.tok
+	}
+	s.call = nil
+	s.mu.Unlock()
+	close(call.done)
+}
+
+// Invalidate drops the cached token if it is still t, so the next call
+// fetches a new one.
+func (s *CachedTokenSource) Invalidate(t *Token) {
+	s.mu.Lock()
+	if s.tok == t {
+		s.tok = nil
+	}
+	s.mu.Unlock()
+}
+
+func (c Client) OAuth2(ts TokenSource) Client {
+	return c.Interceptor(OAuth2Interceptor(ts))
+}
+
+// OAuth2Interceptor authorizes requests with tokens of ts. On a 401 response
+// the token is invalidated and the request is retried once. A ts without
+// Invalidate is wrapped by ReuseTokenSource.
+func OAuth2Interceptor(ts TokenSource) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	inv, ok := ts.(interface{ Invalidate(t *Token) })
+	if !ok {
+		cached := ReuseTokenSource(ts)
+		ts, inv = cached, cached
+	}
+	return func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			r := req.Request()
+			tok, err := ts.Token(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			r.Header.Set("Authorization", tok.Type()+" "+tok.AccessToken)
+			resp, err := next(req)
+			if err != nil || resp.Response().StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+			if err := rewindRequestBody(r); err != nil {
+				return resp, nil
+			}
+			resp.Response().Body.Close()
+			inv.Invalidate(tok)
+			if tok, err = ts.Token(req.Context()); err != nil {
+				return nil, err
+			}
+			r.Header.Set("Authorization", tok.Type()+" "+tok.AccessToken)
+			return next(req)
+		}
+	}
+}
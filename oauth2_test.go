@@ -0,0 +1,145 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var issued int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" || r.FormValue("grant_type") != "client_credentials" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		n := atomic.AddInt32(&issued, 1)
+		fmt.Fprintf(w, `{"access_token":"t%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer tokenSrv.Close()
+
+	var revoked int32
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer t1" && atomic.LoadInt32(&revoked) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, _ := _ReadAll(r.Body)
+		w.Write([]byte(r.Header.Get("Authorization") + " " + string(b)))
+	}))
+	defer apiSrv.Close()
+
+	ts := ReuseTokenSource(ClientCredentials(ClientCredentialsConfig{
+		TokenEndpoint: TokenEndpoint{TokenURL: tokenSrv.URL, ClientID: "client", ClientSecret: "secret"},
+		Scopes:        []string{"read", "write"},
+	}))
+	c := NewClient().OAuth2(ts)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s, err := c.Get(context.TODO(), apiSrv.URL).Do().String(); err != nil || s != "Bearer t1 " {
+				t.Error(s, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&issued); n != 1 {
+		t.Fatal("want 1 token issued, got", n)
+	}
+
+	atomic.StoreInt32(&revoked, 1)
+	s, err := c.Post(context.TODO(), apiSrv.URL, "body").Do().String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "Bearer t2 body" {
+		t.Fatal("unexpected response", s)
+	}
+
+	_, err = ClientCredentials(ClientCredentialsConfig{
+		TokenEndpoint: TokenEndpoint{TokenURL: tokenSrv.URL, ClientID: "client", ClientSecret: "wrong"},
+	}).Token(context.TODO())
+	var oerr *OAuth2Error
+	if !errors.As(err, &oerr) || oerr.Code != "invalid_client" || !IsUnauthorized(err) {
+		t.Fatal("want invalid_client, got", err)
+	}
+}
+
+func TestOAuth2RefreshToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("client_id") != "client" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"a-%s","refresh_token":"r-%s","expires_in":60}`, r.FormValue("refresh_token"), r.FormValue("refresh_token"))
+	}))
+	defer srv.Close()
+
+	ts := RefreshTokenSource(TokenEndpoint{TokenURL: srv.URL, ClientID: "client", AuthInParams: true}, &Token{RefreshToken: "0"})
+	tok, err := ts.Token(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "a-0" || !tok.Valid() {
+		t.Fatal("unexpected token", tok)
+	}
+	if tok, _ = ts.Token(context.TODO()); tok.AccessToken != "a-r-0" {
+		t.Fatal("refresh token not rotated", tok)
+	}
+}
+
+func TestOAuth2PlainTokenSource(t *testing.T) {
+	var issued int32
+	ts := TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		return &Token{AccessToken: fmt.Sprint("t", atomic.AddInt32(&issued, 1))}, nil
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer t1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	c := NewClient().OAuth2(ts)
+	for i := 0; i < 2; i++ {
+		if s, err := c.Get(context.TODO(), srv.URL).Do().String(); err != nil || s != "Bearer t2" {
+			t.Fatal(s, err)
+		}
+	}
+	if n := atomic.LoadInt32(&issued); n != 2 {
+		t.Fatal("want 2 tokens issued, got", n)
+	}
+}
+
+func TestCachedTokenSourceFetchTimeout(t *testing.T) {
+	var calls int32
+	ts := ReuseTokenSource(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &Token{AccessToken: "t"}, nil
+	})).FetchTimeout(20 * time.Millisecond)
+
+	if _, err := ts.Token(context.TODO()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("want context.DeadlineExceeded, got", err)
+	}
+	if tok, err := ts.Token(context.TODO()); err != nil || tok.AccessToken != "t" {
+		t.Fatal("fetch not retried", tok, err)
+	}
+}
@@ -0,0 +1,169 @@
+package xhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrMaxPages is returned when more pages remain after MaxPages pages.
+var ErrMaxPages = errors.New("pagination stopped at max pages")
+
+// Link is a link of a RFC 8288 Link header.
+type Link struct {
+	URL    string
+	Rel    string
+	Params map[string]string
+}
+
+// ParseLinkHeader parses `<url>; rel="next"; title="x", <url2>; rel=prev`.
+func ParseLinkHeader(values ...string) []Link {
+	var links []Link
+	for _, v := range values {
+		for len(v) > 0 {
+			start := strings.IndexByte(v, '<')
+			end := strings.IndexByte(v, '>')
+			if start < 0 || end < start {
+				break
+			}
+			link := Link{URL: strings.TrimSpace(v[start+1 : end]), Params: map[string]string{}}
+			v = v[end+1:]
+			next := len(v)
+			quoted := false
+			for i := 0; i < len(v); i++ {
+				if v[i] == '"' {
+					quoted = !quoted
+				} else if v[i] == ',' && !quoted {
+					next = i
+					break
+				}
+			}
+			for _, p := range strings.Split(v[:next], ";") {
+				kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+				if len(kv[0]) == 0 {
+					continue
+				}
+				k, val := strings.ToLower(kv[0]), ""
+				if len(kv) == 2 {
+					val = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+				}
+				link.Params[k] = val
+			}
+			link.Rel = link.Params["rel"]
+			links = append(links, link)
+			if next < len(v) {
+				next++
+			}
+			v = v[next:]
+		}
+	}
+	return links
+}
+
+// NextLink returns the resolved url of the rel="next" link of res, or "".
+func NextLink(res *http.Response) string {
+	for _, l := range ParseLinkHeader(res.Header.Values("Link")...) {
+		for _, rel := range strings.Fields(l.Rel) {
+			if strings.EqualFold(rel, "next") {
+				u, err := url.Parse(l.URL)
+				if err != nil {
+					return ""
+				}
+				if res.Request != nil && res.Request.URL != nil {
+					u = res.Request.URL.ResolveReference(u)
+				}
+				return u.String()
+			}
+		}
+	}
+	return ""
+}
+
+type PaginateConfig struct {
+	// Cursor returns the cursor of the next page from the decoded page,
+	// "" on the last page. Without Cursor the Link rel="next" header is followed.
+	Cursor func(page interface{}) string
+
+	// Optional. Default value "cursor". The query parameter set to the cursor.
+	CursorParam string
+
+	// MaxPages stops with ErrMaxPages after that many pages, 0 means unlimited.
+	MaxPages int
+}
+
+// Paginate sends req, decodes every page into page with the client decoder
+// and calls f, then follows the next page until there is none or f returns
+// an error. Headers of req are sent with every page.
+//
+//	var page struct{ Items []Item; Next string }
+//	err := c.Paginate(c.Get(ctx, "/items"), &page, func() error { ...; return nil },
+//		xhttp.PaginateConfig{Cursor: func(interface{}) string { return page.Next }})
+func (c Client) Paginate(req Request, page interface{}, f func() error, config ...PaginateConfig) error {
+	var cfg PaginateConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if len(cfg.CursorParam) == 0 {
+		cfg.CursorParam = "cursor"
+	}
+	// the context of the caller, the request context of a call may carry its timeout
+	ctx := req.Context()
+	seen := map[string]bool{}
+	for pages := 1; ; pages++ {
+		resp := req.Do()
+		if err := resp.Error(); err != nil && resp.Response() == nil {
+			return err
+		}
+		r := req.Request()
+		seen[r.URL.String()] = true
+		if res := resp.Response(); res != nil && res.Request != nil {
+			seen[res.Request.URL.String()] = true
+		}
+		resetValue(page)
+		if err := resp.DecodeResult(page, nil); err != nil && err != io.EOF {
+			return err
+		}
+		if err := f(); err != nil {
+			return err
+		}
+
+		var next string
+		if cfg.Cursor != nil {
+			cursor := cfg.Cursor(page)
+			if len(cursor) == 0 {
+				return nil
+			}
+			u := *r.URL
+			q := u.Query()
+			q.Set(cfg.CursorParam, cursor)
+			u.RawQuery = q.Encode()
+			next = u.String()
+		} else if next = NextLink(resp.Response()); len(next) == 0 {
+			return nil
+		}
+		if seen[next] {
+			return nil
+		}
+		if cfg.MaxPages > 0 && pages >= cfg.MaxPages {
+			return ErrMaxPages
+		}
+
+		var body io.ReadCloser
+		if r.GetBody != nil {
+			b, err := r.GetBody()
+			if err != nil {
+				return err
+			}
+			body = b
+		}
+		nr := c.Request(ctx, r.Method, next, body)
+		for k, vs := range r.Header {
+			for _, v := range vs {
+				nr.AddHeader(k, v)
+			}
+		}
+		req = nr
+	}
+}
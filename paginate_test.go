@@ -0,0 +1,86 @@
+package xhttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseLinkHeader(t *testing.T) {
+	links := ParseLinkHeader(`<https://api.example.com/items?page=2>; rel="next"; title="a, b", </items?page=5>; rel=last`)
+	if len(links) != 2 || links[0].Rel != "next" || links[0].Params["title"] != "a, b" ||
+		links[1].URL != "/items?page=5" || links[1].Rel != "last" {
+		t.Fatal("unexpected links", links)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/link":
+			p, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if p < 3 {
+				w.Header().Set("Link", fmt.Sprintf(`</link?page=%d>; rel="next"`, p+1))
+			}
+			fmt.Fprintf(w, `{"items":[%d,%d]}`, 2*p, 2*p+1)
+		case "/cursor":
+			switch r.URL.Query().Get("after") {
+			case "":
+				w.Write([]byte(`{"items":[1],"next":"a"}`))
+			case "a":
+				w.Write([]byte(`{"items":[2]}`))
+			}
+		}
+	}))
+	defer srv.Close()
+
+	type page struct {
+		Items []int  `json:"items"`
+		Next  string `json:"next"`
+	}
+	c := NewClient()
+	var p page
+	var items []int
+	collect := func() error {
+		items = append(items, p.Items...)
+		return nil
+	}
+	err := c.Paginate(c.Get(context.TODO(), srv.URL+"/link?page=0").SetHeader("X-Token", "t"), &p, collect)
+	if err != nil || fmt.Sprint(items) != "[0 1 2 3 4 5 6 7]" {
+		t.Fatal("unexpected items", items, err)
+	}
+
+	items = nil
+	tc := NewClient().Timeout(5 * time.Second)
+	err = tc.Paginate(tc.Get(context.TODO(), srv.URL+"/link?page=0").SetHeader("X-Token", "t"), &p, collect)
+	if err != nil || fmt.Sprint(items) != "[0 1 2 3 4 5 6 7]" {
+		t.Fatal("unexpected items with timeout", items, err)
+	}
+
+	items = nil
+	err = c.Paginate(c.Get(context.TODO(), srv.URL+"/link?page=0").SetHeader("X-Token", "t"), &p, collect, PaginateConfig{MaxPages: 2})
+	if err != ErrMaxPages || len(items) != 4 {
+		t.Fatal("want ErrMaxPages after 2 pages, got", items, err)
+	}
+
+	items = nil
+	err = c.Paginate(c.Get(context.TODO(), srv.URL+"/cursor").SetHeader("X-Token", "t"), &p, collect, PaginateConfig{
+		Cursor:      func(interface{}) string { return p.Next },
+		CursorParam: "after",
+	})
+	if err != nil || fmt.Sprint(items) != "[1 2]" {
+		t.Fatal("unexpected items", items, err)
+	}
+
+	if err := c.Paginate(c.Get(context.TODO(), srv.URL+"/link"), &p, collect); !IsUnauthorized(err) {
+		t.Fatal("want 401, got", err)
+	}
+}
@@ -0,0 +1,55 @@
+package xhttp
+
+import (
+	"io"
+	"time"
+)
+
+// ProgressInterval throttles the progress callbacks of requests and
+// responses to at most one call per interval, the last one is always
+// reported. Zero reports on every read.
+func (c Client) ProgressInterval(d time.Duration) Client {
+	c.progressInterval = d
+	return c
+}
+
+type progressReader struct {
+	io.ReadCloser
+	n        int64
+	total    int64
+	interval time.Duration
+	last     time.Time
+	f        func(n int64, total int64)
+
+	// reported is set once the final value has been reported.
+	reported bool
+}
+
+func newProgressReader(rc io.ReadCloser, total int64, interval time.Duration, f func(n int64, total int64)) *progressReader {
+	if total < 0 {
+		total = -1
+	}
+	return &progressReader{ReadCloser: rc, total: total, interval: interval, f: f}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if (n <= 0 && err != io.EOF) || r.reported {
+		return n, err
+	}
+	r.n += int64(n)
+	if err == io.EOF || r.n == r.total {
+		r.reported = true
+		r.f(r.n, r.total)
+		return n, err
+	}
+	if r.interval <= 0 {
+		r.f(r.n, r.total)
+		return n, err
+	}
+	if now := time.Now(); now.Sub(r.last) >= r.interval {
+		r.last = now
+		r.f(r.n, r.total)
+	}
+	return n, err
+}
@@ -0,0 +1,65 @@
+package xhttp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDownloadProgress(t *testing.T) {
+	body := bytes.Repeat([]byte("x"), 64<<10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		WriteBytes(w, http.StatusOK, "", body)
+	}))
+	defer srv.Close()
+
+	var calls int
+	var received, total int64
+	progress := func(n int64, t int64) {
+		calls++
+		received, total = n, t
+	}
+
+	var buf bytes.Buffer
+	n, err := NewClient().Get(context.TODO(), srv.URL).Do().DownloadProgress(progress).WriteTo(&buf)
+	if err != nil || n != int64(len(body)) {
+		t.Fatal(n, err)
+	}
+	if received != int64(len(body)) || total != int64(len(body)) || calls == 0 {
+		t.Fatal("wrong progress", received, total, calls)
+	}
+
+	calls = 0
+	c := NewClient().ProgressInterval(time.Hour)
+	if _, err := c.Get(context.TODO(), srv.URL).Do().DownloadProgress(progress).Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	if calls > 2 || received != int64(len(body)) {
+		t.Fatal("want throttled progress, got", calls, received)
+	}
+}
+
+func TestUploadProgressFinalOnce(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	var final int
+	progress := func(n int64, total int64) {
+		if n == 100 && total == 100 {
+			final++
+		}
+	}
+	if _, err := NewClient().Post(context.TODO(), srv.URL, bytes.Repeat([]byte("x"), 100)).UploadProgress(progress).Do().Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	if final != 1 {
+		t.Fatal("want the final progress once, got", final)
+	}
+}
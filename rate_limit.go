@@ -0,0 +1,303 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRateLimited        = errors.New("rate limited")
+	ErrConcurrencyLimited = errors.New("concurrency limited")
+)
+
+// HostKey is the default key func of the client limiters, limiting per host.
+func HostKey(req Request) string {
+	if u := req.Request().URL; u != nil {
+		return u.Host
+	}
+	return ""
+}
+
+type RateLimitConfig struct {
+	// tokens per second
+	Rate float64
+
+	// Optional. Default value 1.
+	Burst int
+
+	// Wait for a token until the request context is done, otherwise fail fast with ErrRateLimited.
+	Wait bool
+
+	// Optional. Default value HostKey.
+	KeyFunc func(req Request) string
+}
+
+func (c Client) RateLimit(rate float64, burst int) Client {
+	return c.Interceptor(RateLimitInterceptor(RateLimitConfig{Rate: rate, Burst: burst, Wait: true}))
+}
+
+func RateLimitInterceptor(config RateLimitConfig) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	if config.Rate <= 0 {
+		panic("rate limit requires a positive rate")
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = HostKey
+	}
+	buckets := newBucketSet(config.Rate, config.Burst)
+	return func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			b := buckets.acquire(config.KeyFunc(req))
+			if !config.Wait {
+				ok := b.allow()
+				buckets.release(b)
+				if !ok {
+					return nil, ErrRateLimited
+				}
+				return next(req)
+			}
+			err := b.wait(req.Request().Context())
+			buckets.release(b)
+			if err != nil {
+				return nil, err
+			}
+			return next(req)
+		}
+	}
+}
+
+// minBucketSweep is the number of buckets kept before idle ones are swept.
+const minBucketSweep = 64
+
+// bucketSet holds the token bucket of every key. A bucket that is full
+// again is the same as a new one, so unused full buckets are dropped once
+// the set has doubled since the last sweep.
+type bucketSet struct {
+	rate  float64
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	sweepAt int
+}
+
+func newBucketSet(rate float64, burst int) *bucketSet {
+	return &bucketSet{rate: rate, burst: burst, buckets: make(map[string]*tokenBucket), sweepAt: minBucketSweep}
+}
+
+// acquire returns the bucket of key, it is kept until release.
+func (s *bucketSet) acquire(key string) *tokenBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= s.sweepAt {
+			s.sweep(time.Now())
+		}
+		b = newTokenBucket(s.rate, s.burst)
+		s.buckets[key] = b
+	}
+	b.users++
+	return b
+}
+
+func (s *bucketSet) release(b *tokenBucket) {
+	s.mu.Lock()
+	b.users--
+	s.mu.Unlock()
+}
+
+func (s *bucketSet) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.users == 0 && b.full(now) {
+			delete(s.buckets, key)
+		}
+	}
+	if s.sweepAt = 2 * len(s.buckets); s.sweepAt < minBucketSweep {
+		s.sweepAt = minBucketSweep
+	}
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// users is guarded by the mutex of the bucketSet.
+	users int
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+func (b *tokenBucket) advance(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+func (b *tokenBucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	return b.tokens >= b.burst
+}
+
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+	return false
+}
+
+// reserve takes a token, possibly going into debt, and returns how long to wait for it.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.mu.Unlock()
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d := b.reserve()
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
+
+type ConcurrencyLimitConfig struct {
+	Limit int
+
+	// Wait for a free slot until the request context is done, otherwise fail fast with ErrConcurrencyLimited.
+	Wait bool
+
+	// Optional. Default value HostKey.
+	KeyFunc func(req Request) string
+}
+
+func (c Client) ConcurrencyLimit(limit int) Client {
+	return c.Interceptor(ConcurrencyLimitInterceptor(ConcurrencyLimitConfig{Limit: limit, Wait: true}))
+}
+
+// ConcurrencyLimitInterceptor holds a slot until the response body is closed,
+// so a call is in flight for as long as its body is being read.
+func ConcurrencyLimitInterceptor(config ConcurrencyLimitConfig) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	if config.Limit <= 0 {
+		panic("concurrency limit requires a positive limit")
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = HostKey
+	}
+	sems := newSemaphoreSet(config.Limit)
+	return func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			key := config.KeyFunc(req)
+			sem := sems.acquire(key)
+			if config.Wait {
+				ctx := req.Request().Context()
+				select {
+				case sem.slots <- struct{}{}:
+				case <-ctx.Done():
+					sems.release(key, sem)
+					return nil, ctx.Err()
+				}
+			} else {
+				select {
+				case sem.slots <- struct{}{}:
+				default:
+					sems.release(key, sem)
+					return nil, ErrConcurrencyLimited
+				}
+			}
+			release := func() {
+				<-sem.slots
+				sems.release(key, sem)
+			}
+			resp, err := next(req)
+			if err != nil || resp == nil {
+				release()
+				return resp, err
+			}
+			OnBodyClose(resp.Response(), release)
+			return resp, err
+		}
+	}
+}
+
+type semaphore struct {
+	slots chan struct{}
+	users int
+}
+
+// semaphoreSet holds the semaphore of every key, dropping it as soon as
+// no call holds or waits for a slot.
+type semaphoreSet struct {
+	limit int
+
+	mu   sync.Mutex
+	sems map[string]*semaphore
+}
+
+func newSemaphoreSet(limit int) *semaphoreSet {
+	return &semaphoreSet{limit: limit, sems: make(map[string]*semaphore)}
+}
+
+func (s *semaphoreSet) acquire(key string) *semaphore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sem, ok := s.sems[key]
+	if !ok {
+		sem = &semaphore{slots: make(chan struct{}, s.limit)}
+		s.sems[key] = sem
+	}
+	sem.users++
+	return sem
+}
+
+func (s *semaphoreSet) release(key string, sem *semaphore) {
+	s.mu.Lock()
+	if sem.users--; sem.users == 0 {
+		delete(s.sems, key)
+	}
+	s.mu.Unlock()
+}
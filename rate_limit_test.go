@@ -0,0 +1,102 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitInterceptor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	c := NewClient().Interceptor(RateLimitInterceptor(RateLimitConfig{Rate: 1, Burst: 2}))
+	for i := 0; i < 2; i++ {
+		if err := c.Get(context.TODO(), srv.URL).Do().Error(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Get(context.TODO(), srv.URL).Do().Error(); err != ErrRateLimited {
+		t.Fatal("want ErrRateLimited, got", err)
+	}
+
+	c = NewClient().Interceptor(RateLimitInterceptor(RateLimitConfig{Rate: 1, Burst: 1, Wait: true}))
+	c.Get(context.TODO(), srv.URL).Do().Bytes()
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Get(ctx, srv.URL).Do().Error(); err != context.DeadlineExceeded {
+		t.Fatal("want context.DeadlineExceeded, got", err)
+	}
+}
+
+func TestConcurrencyLimitInterceptor(t *testing.T) {
+	var cur, max int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&cur, 1)
+		defer atomic.AddInt32(&cur, -1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	c := NewClient().ConcurrencyLimit(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Get(context.TODO(), srv.URL).Do().Bytes(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if max > 2 {
+		t.Fatal("want at most 2 concurrent requests, got", max)
+	}
+}
+
+func TestLimiterEviction(t *testing.T) {
+	buckets := newBucketSet(1e9, 1)
+	for i := 0; i < 1000; i++ {
+		b := buckets.acquire(strconv.Itoa(i))
+		b.allow()
+		buckets.release(b)
+	}
+	if n := len(buckets.buckets); n > minBucketSweep {
+		t.Fatal("idle buckets not evicted", n)
+	}
+
+	buckets = newBucketSet(1.0/3600, 1)
+	busy := buckets.acquire("busy")
+	busy.allow()
+	buckets.release(busy)
+	for i := 0; i < 1000; i++ {
+		buckets.release(buckets.acquire(strconv.Itoa(i)))
+	}
+	if buckets.buckets["busy"] != busy {
+		t.Fatal("bucket evicted before it was full")
+	}
+
+	sems := newSemaphoreSet(1)
+	a := sems.acquire("a")
+	a2 := sems.acquire("a")
+	sems.release("a", a2)
+	if len(sems.sems) != 1 {
+		t.Fatal("semaphore in use evicted")
+	}
+	sems.release("a", a)
+	if len(sems.sems) != 0 {
+		t.Fatal("idle semaphore not evicted")
+	}
+}
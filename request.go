@@ -4,40 +4,59 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
-func getBodyReader(encoder Encoder, v interface{}) (rc io.ReadCloser, err error) {
-	switch b := v.(type) {
+func getBodyReader(encoder Encoder, v interface{}) (rc io.ReadCloser, getBody func() (io.ReadCloser, error), err error) {
+	var b []byte
+	switch x := v.(type) {
 	case nil:
-		rc = nil
+		return nil, nil, nil
 	case io.ReadCloser:
-		rc = b
+		return x, nil, nil
 	case io.Reader:
-		rc = _NopCloser(b)
+		return _NopCloser(x), nil, nil
 	case []byte:
-		rc = _NopCloser(bytes.NewBuffer(b))
+		b = x
 	case string:
-		rc = _NopCloser(strings.NewReader(b))
+		b = []byte(x)
 	default:
 		var d bytes.Buffer
 		if encoder == nil {
 			encoder = JSON
 		}
-		if err = encoder.Encode(&d, v); err == nil {
-			rc = _NopCloser(&d)
+		if err = encoder.Encode(&d, v); err != nil {
+			return nil, nil, err
 		}
+		b = d.Bytes()
 	}
-	return rc, err
+	getBody = func() (io.ReadCloser, error) { return bytesBody{bytes.NewReader(b)}, nil }
+	rc, _ = getBody()
+	return rc, getBody, nil
 }
 
+// bytesBody keeps Len of the reader visible, so Perpare can set the content length.
+type bytesBody struct {
+	*bytes.Reader
+}
+
+func (bytesBody) Close() error {
+	return nil
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^/{}]+)\}`)
+
 func newRequest(ctx context.Context, cli *Client, method, url string, body interface{}) Request {
-	if len(cli.baseURL) > 0 {
+	if len(cli.baseURL) > 0 && !strings.Contains(url, "://") {
 		url = cli.baseURL + strings.TrimPrefix(url, "/")
 	}
 	req, err := http.NewRequestWithContext(ctx, method, url, nil)
@@ -50,6 +69,13 @@ type Request interface {
 	SetError(err error) Request
 	Error() error
 	Request() *http.Request
+	Context() context.Context
+	WithContext(ctx context.Context) Request
+	Timeout(d time.Duration) Request
+	Query(k string, v string) Request
+	Queries(v interface{}) Request
+	PathParam(name string, value string) Request
+	PathParams(params map[string]string) Request
 	Do() Response
 	Body(body interface{}) Request
 	Interceptor(f func(req Request) error) Request
@@ -59,6 +85,8 @@ type Request interface {
 	AddHeaders(headers map[string]string) Request
 	SetHeaders(headers map[string]string) Request
 	ContentType(contentType string) Request
+	BasicAuth(username string, password string) Request
+	Bearer(token string) Request
 	JSON() Request
 	Gob() Request
 	XML() Request
@@ -66,6 +94,12 @@ type Request interface {
 	Fields(fields map[string]string) Request
 	Field(name string, value string) Request
 	File(name string, file string) Request
+	FileReader(name string, filename string, r io.Reader, contentType string) Request
+	FileBytes(name string, filename string, b []byte, contentType string) Request
+	FormStruct(v interface{}) Request
+	UploadProgress(f func(sent int64, total int64)) Request
+	Curl() (string, error)
+	requestFS
 	Perpare() error
 }
 
@@ -75,8 +109,15 @@ type request struct {
 	err        error
 	req        *http.Request
 	cli        *Client
-	mw         *multipart.Writer
 	formValues url.Values
+	parts      []*formPart
+	multipart  bool
+	timeoutDur time.Duration
+	queries    url.Values
+	pathParams map[string]string
+	perpared   bool
+
+	uploadProgress func(sent int64, total int64)
 }
 
 func (r *request) SetError(err error) Request {
@@ -92,6 +133,49 @@ func (r *request) Request() *http.Request {
 	return r.req
 }
 
+func (r *request) Context() context.Context {
+	if r.req == nil {
+		return context.Background()
+	}
+	return r.req.Context()
+}
+
+func (r *request) WithContext(ctx context.Context) Request {
+	if r.req != nil {
+		r.req = r.req.WithContext(ctx)
+	}
+	return r
+}
+
+// Timeout bounds the whole call, including reading the response body,
+// and overrides the total timeout of the client.
+func (r *request) Timeout(d time.Duration) Request {
+	r.timeoutDur = d
+	return r
+}
+
+func (r *request) timeout() time.Duration {
+	return r.timeoutDur
+}
+
+func (r *request) client() *Client {
+	return r.cli
+}
+
+// forCall returns a shallow copy for a single call, so per-call contexts
+// and url rewrites do not stick to a request that is sent again.
+func (r *request) forCall() Request {
+	cp := *r
+	if r.req != nil {
+		req := *r.req
+		u := *r.req.URL
+		req.URL = &u
+		req.Header = r.req.Header.Clone()
+		cp.req = &req
+	}
+	return &cp
+}
+
 func (r *request) Do() Response {
 	return r.cli.Do(r)
 }
@@ -100,7 +184,9 @@ func (r *request) Body(body interface{}) Request {
 	if r.err != nil {
 		return r
 	}
-	r.req.Body, r.err = getBodyReader(r.cli.encoder, body)
+	var getBody func() (io.ReadCloser, error)
+	r.req.Body, getBody, r.err = getBodyReader(r.cli.encoder, body)
+	r.req.GetBody = getBody
 	return r
 }
 
@@ -166,34 +252,155 @@ func (r *request) XML() Request {
 	return r.ContentType("application/xml")
 }
 
-func (r *request) File(name string, file string) Request {
+func (r *request) Query(k string, v string) Request {
+	if r.queries == nil {
+		r.queries = url.Values{}
+	}
+	r.queries.Add(k, v)
+	return r
+}
+
+// Queries adds query parameters from a map[string]string, url.Values
+// or a struct encoded by EncodeValues with the "query" tag.
+func (r *request) Queries(v interface{}) Request {
 	if r.err != nil {
 		return r
 	}
-	if r.formValues != nil {
-		r.err = errors.New("File() must be called before Field()")
-		return r
+	var values url.Values
+	switch q := v.(type) {
+	case nil:
+	case map[string]string:
+		values = url.Values{}
+		for k, v := range q {
+			values.Add(k, v)
+		}
+	case url.Values:
+		values = q
+	case map[string][]string:
+		values = q
+	default:
+		values, r.err = EncodeValues(v, "query")
 	}
-	if r.mw == nil {
-		body := new(bytes.Buffer)
-		r.Body(body)
-		r.mw = multipart.NewWriter(body)
+	for k, vs := range values {
+		for _, v := range vs {
+			r.Query(k, v)
+		}
 	}
-	fw, err := r.mw.CreateFormFile(name, file)
-	if err != nil {
-		r.err = err
+	return r
+}
+
+// PathParam replaces the "{name}" segment of the url path with the escaped value.
+func (r *request) PathParam(name string, value string) Request {
+	if r.pathParams == nil {
+		r.pathParams = make(map[string]string)
+	}
+	r.pathParams[name] = value
+	return r
+}
+
+func (r *request) PathParams(params map[string]string) Request {
+	for k, v := range params {
+		r.PathParam(k, v)
+	}
+	return r
+}
+
+func (r *request) perpareURL() error {
+	u := r.req.URL
+	if len(r.pathParams) > 0 {
+		path, rawPath := u.Path, u.EscapedPath()
+		for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+			v, ok := r.pathParams[m[1]]
+			if !ok {
+				return errors.New("missing path param " + m[0])
+			}
+			path = strings.Replace(path, m[0], v, -1)
+			rawPath = strings.Replace(rawPath, m[0], url.PathEscape(v), -1)
+			rawPath = strings.Replace(rawPath, "%7B"+m[1]+"%7D", url.PathEscape(v), -1)
+		}
+		u.Path, u.RawPath = path, rawPath
+	}
+	if len(r.queries) > 0 {
+		if len(u.RawQuery) > 0 {
+			u.RawQuery += "&" + r.queries.Encode()
+		} else {
+			u.RawQuery = r.queries.Encode()
+		}
+	}
+	return nil
+}
+
+// File adds a file part, the file is only opened while the body is sent.
+func (r *request) File(name string, file string) Request {
+	if r.err != nil {
 		return r
 	}
-	f, err := os.Open(file)
+	fi, err := os.Stat(file)
 	if err != nil {
 		r.err = err
 		return r
 	}
-	defer f.Close()
-	_, r.err = io.Copy(fw, f)
+	return r.filePart(&formPart{
+		name:       name,
+		filename:   file,
+		isFile:     true,
+		open:       func() (io.ReadCloser, error) { return os.Open(file) },
+		reopenable: true,
+		size:       fi.Size(),
+		path:       file,
+	})
+}
+
+// FileReader adds a file part read from rd. The content type defaults to
+// "application/octet-stream".
+func (r *request) FileReader(name string, filename string, rd io.Reader, contentType string) Request {
+	if r.err != nil {
+		return r
+	}
+	return r.filePart(readerPart(name, filename, rd, contentType))
+}
+
+func (r *request) FileBytes(name string, filename string, b []byte, contentType string) Request {
+	if r.err != nil {
+		return r
+	}
+	return r.filePart(&formPart{
+		name:        name,
+		filename:    filename,
+		contentType: contentType,
+		isFile:      true,
+		open:        func() (io.ReadCloser, error) { return _NopCloser(bytes.NewReader(b)), nil },
+		reopenable:  true,
+		size:        int64(len(b)),
+	})
+}
+
+func (r *request) filePart(p *formPart) Request {
+	r.parts = append(r.parts, p)
+	r.multipart = true
+	return r
+}
+
+// UploadProgress reports the bytes of the request body sent so far,
+// total is -1 when the length of the body is unknown.
+func (r *request) UploadProgress(f func(sent int64, total int64)) Request {
+	r.uploadProgress = f
 	return r
 }
 
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func filePartHeader(name string, filename string, contentType string) textproto.MIMEHeader {
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(name), quoteEscaper.Replace(filename)))
+	h.Set("Content-Type", contentType)
+	return h
+}
+
 func (r *request) Form() url.Values {
 	return r.formValues
 }
@@ -202,14 +409,35 @@ func (r *request) Field(name string, value string) Request {
 	if r.err != nil {
 		return r
 	}
-	if r.mw != nil { // multipart/form-data
-		r.mw.WriteField(name, value)
-		return r
-	}
 	if r.formValues == nil {
 		r.formValues = url.Values{}
 	}
 	r.formValues.Add(name, value)
+	r.parts = append(r.parts, &formPart{name: name, value: value})
+	return r
+}
+
+// FormStruct adds the fields of v, encoded by EncodeValues with the "form" tag.
+// They are sent as multipart/form-data once a file has been added.
+func (r *request) FormStruct(v interface{}) Request {
+	if r.err != nil {
+		return r
+	}
+	values, err := EncodeValues(v, "form")
+	if err != nil {
+		r.err = err
+		return r
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range values[k] {
+			r.Field(k, v)
+		}
+	}
 	return r
 }
 
@@ -223,16 +451,28 @@ func (r *request) Fields(fields map[string]string) Request {
 	return r
 }
 
+// Perpare builds the url and the body, it only runs once.
 func (r *request) Perpare() error {
-	if r.err != nil {
+	if r.err != nil || r.perpared {
 		return r.err
 	}
-	if r.mw != nil {
-		r.err = r.mw.Close()
-		r.ContentType(r.mw.FormDataContentType())
+	r.perpared = true
+	if r.err = r.perpareURL(); r.err != nil {
+		return r.err
 	}
-	if r.formValues != nil {
-		r.Body(strings.NewReader(r.formValues.Encode()))
+	if r.multipart { // multipart/form-data
+		mb := newMultipartBody(r.parts)
+		r.req.Body = mb.Reader()
+		r.req.ContentLength = mb.ContentLength()
+		if mb.Reopenable() {
+			r.req.GetBody = func() (io.ReadCloser, error) { return mb.Reader(), nil }
+		}
+		r.ContentType(mb.ContentType())
+	} else if r.formValues != nil {
+		form := r.formValues.Encode()
+		r.req.Body = _NopCloser(strings.NewReader(form))
+		r.req.ContentLength = int64(len(form))
+		r.req.GetBody = func() (io.ReadCloser, error) { return _NopCloser(strings.NewReader(form)), nil }
 		r.ContentType("application/x-www-form-urlencoded")
 	}
 	if r.req.Body != nil {
@@ -240,5 +480,21 @@ func (r *request) Perpare() error {
 			r.req.ContentLength = int64(l.Len())
 		}
 	}
+	if r.uploadProgress != nil && r.req.Body != nil && r.req.Body != http.NoBody {
+		f, total, interval := r.uploadProgress, r.req.ContentLength, r.cli.progressInterval
+		if total <= 0 {
+			total = -1
+		}
+		r.req.Body = newProgressReader(r.req.Body, total, interval, f)
+		if getBody := r.req.GetBody; getBody != nil {
+			r.req.GetBody = func() (io.ReadCloser, error) {
+				rc, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return newProgressReader(rc, total, interval, f), nil
+			}
+		}
+	}
 	return r.err
 }
@@ -0,0 +1,36 @@
+//go:build go1.16
+
+package xhttp
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+)
+
+type requestFS interface {
+	FileFS(name string, fsys fs.FS, file string) Request
+}
+
+// FileFS adds a file part read from fsys, typed by its extension. Like
+// File, the file is only opened while the body is sent.
+func (r *request) FileFS(name string, fsys fs.FS, file string) Request {
+	if r.err != nil {
+		return r
+	}
+	fi, err := fs.Stat(fsys, file)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	return r.filePart(&formPart{
+		name:        name,
+		filename:    path.Base(file),
+		contentType: mime.TypeByExtension(path.Ext(file)),
+		isFile:      true,
+		open:        func() (io.ReadCloser, error) { return fsys.Open(file) },
+		reopenable:  true,
+		size:        fi.Size(),
+	})
+}
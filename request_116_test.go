@@ -0,0 +1,40 @@
+//go:build go1.16
+
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileFS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, fh, err := r.FormFile("file")
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer f.Close()
+		b, _ := _ReadAll(f)
+		WriteString(w, http.StatusOK, "", fh.Filename+":"+fh.Header.Get("Content-Type")+":"+string(b))
+	}))
+	defer srv.Close()
+
+	dir, err := _MkdirTemp("", "xhttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := _WriteFile(filepath.Join(dir, "a.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewClient().Post(context.TODO(), srv.URL, nil).FileFS("file", os.DirFS(dir), "a.json").Do().String()
+	if want := "a.json:application/json:{}"; err != nil || got != want {
+		t.Fatalf("want %v, got %v %v", want, got, err)
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !go1.16
+
+package xhttp
+
+type requestFS interface{}
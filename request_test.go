@@ -0,0 +1,156 @@
+package xhttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryAndPathParam(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteString(w, http.StatusOK, "", r.URL.EscapedPath()+"?"+r.URL.RawQuery)
+	}))
+	defer srv.Close()
+
+	type filter struct {
+		Name  string    `query:"name"`
+		Tags  []string  `query:"tag"`
+		Page  int       `query:"page,omitempty"`
+		Since time.Time `query:"since"`
+		Skip  string    `query:"-"`
+	}
+
+	c := NewClient().BaseURL(srv.URL + "/api")
+	got, err := c.Get(context.TODO(), "/users/{id}/files/{name}?a=1").
+		PathParam("id", "42").
+		PathParam("name", "a b/c").
+		Query("b", "2").
+		Queries(filter{Name: "x&y", Tags: []string{"t1", "t2"}, Since: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Skip: "skip"}).
+		Do().String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "/api/users/42/files/a%20b%2Fc?a=1&b=2&name=x%26y&since=2020-01-02T03%3A04%3A05Z&tag=t1&tag=t2"
+	if got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	if err := c.Get(context.TODO(), "/users/{id}").PathParam("name", "x").Do().Error(); err == nil {
+		t.Fatal("want missing path param error")
+	}
+
+	got, err = c.Get(context.TODO(), srv.URL+"/abs").Do().String()
+	if err != nil || got != "/abs?" {
+		t.Fatal("want absolute url to skip base url, got", got, err)
+	}
+}
+
+func TestFormStruct(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s := r.PostForm.Encode()
+		if r.MultipartForm != nil {
+			for name, fhs := range r.MultipartForm.File {
+				for _, fh := range fhs {
+					f, _ := fh.Open()
+					b, _ := _ReadAll(f)
+					f.Close()
+					s += "|" + name + ":" + fh.Filename + ":" + fh.Header.Get("Content-Type") + ":" + string(b)
+				}
+			}
+		}
+		WriteString(w, http.StatusOK, "", s)
+	}))
+	defer srv.Close()
+
+	type address struct {
+		City string `form:"city"`
+	}
+	type item struct {
+		Name string `form:"name"`
+	}
+	type form struct {
+		Name    string   `form:"name"`
+		Tags    []string `form:"tag"`
+		Address address  `form:"address"`
+		Items   []item   `form:"items"`
+		Note    string   `form:"note,omitempty"`
+	}
+	v := form{Name: "john", Tags: []string{"a", "b"}, Address: address{City: "x"}, Items: []item{{"i0"}, {"i1"}}}
+
+	got, err := NewClient().Post(context.TODO(), srv.URL, nil).FormStruct(v).Do().String()
+	want := "address.city=x&items.0.name=i0&items.1.name=i1&name=john&tag=a&tag=b"
+	if err != nil || got != want {
+		t.Fatalf("want %v, got %v %v", want, got, err)
+	}
+
+	got, err = NewClient().Post(context.TODO(), srv.URL, nil).
+		FileBytes("doc", "a.json", []byte(`{}`), "application/json").
+		FormStruct(v).Do().String()
+	want += "|doc:a.json:application/json:{}"
+	if err != nil || got != want {
+		t.Fatalf("want %v, got %v %v", want, got, err)
+	}
+}
+
+func TestMultipartStreaming(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		f, fh, err := r.FormFile("file")
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer f.Close()
+		b, _ := _ReadAll(f)
+		WriteString(w, http.StatusOK, "", strconv.FormatInt(r.ContentLength, 10)+":"+r.FormValue("a")+":"+fh.Filename+":"+strconv.Itoa(len(b)))
+	}))
+	defer srv.Close()
+
+	dir, err := _MkdirTemp("", "xhttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "a.txt")
+	if err := _WriteFile(file, bytes.Repeat([]byte("x"), 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent, total int64
+	req := NewClient().Post(context.TODO(), srv.URL, nil).
+		Field("a", "1").
+		File("file", file).
+		UploadProgress(func(n int64, t int64) { sent, total = n, t })
+	got, err := req.Do().String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strconv.FormatInt(total, 10) + ":1:a.txt:4096"; got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if sent != total || total <= 4096 {
+		t.Fatal("wrong progress", sent, total)
+	}
+
+	got, err = NewClient().Post(context.TODO(), srv.URL, nil).
+		FileReader("file", "b.txt", io.MultiReader(strings.NewReader("y")), "").
+		Do().String()
+	if err != nil || got != "-1::b.txt:1" {
+		t.Fatal("want chunked upload, got", got, err)
+	}
+}
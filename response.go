@@ -1,9 +1,13 @@
 package xhttp
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
 	"net/http"
 	"os"
+	"time"
 )
 
 func decodeBody(decoder Decoder, r io.ReadCloser, v interface{}) error {
@@ -33,6 +37,7 @@ type Response interface {
 	Response() *http.Response
 	Interceptor(f func(res Response) error) Response
 	Decode(v interface{}, decoder ...Decoder) error
+	DecodeResult(ok interface{}, failed interface{}, decoder ...Decoder) error
 	String() (string, error)
 	Bytes() ([]byte, error)
 	JSON(v interface{}) error
@@ -40,14 +45,38 @@ type Response interface {
 	XML(v interface{}) error
 	File(name string, perm os.FileMode) (int64, error)
 	WriteTo(w io.Writer) (int64, error)
+	DownloadProgress(f func(received int64, total int64)) Response
+	Timings() *Timings
+	Events(f func(e *Event) error, config ...EventsConfig) error
+	NDJSON(v interface{}, f func() error) error
+	JSONArray(v interface{}, f func() error) error
 }
 
 var _ Response = (*response)(nil)
 
+// NewResponse wraps res as the response of req, it is meant for
+// interceptors answering a request without calling next.
+func NewResponse(req Request, res *http.Response, err error) Response {
+	var cli *Client
+	if r, ok := req.(interface{ client() *Client }); ok {
+		cli = r.client()
+	}
+	if res != nil && res.Request == nil {
+		res.Request = req.Request()
+	}
+	return &response{err: err, res: res, cli: cli}
+}
+
 type response struct {
-	err error
-	res *http.Response
-	cli *Client
+	err     error
+	res     *http.Response
+	cli     *Client
+	timings *timingsHolder
+
+	// ctx and origin are the context and the request of the caller, unlike
+	// res.Request they are not touched by the timeouts and interceptors of the call.
+	ctx    context.Context
+	origin *http.Request
 }
 
 func (r *response) SetError(err error) Response {
@@ -71,6 +100,20 @@ func (r *response) Interceptor(f func(res Response) error) Response {
 	return r
 }
 
+// DownloadProgress reports the bytes of the response body read so far,
+// total is -1 when the response has no Content-Length.
+func (r *response) DownloadProgress(f func(received int64, total int64)) Response {
+	if r.res == nil || r.res.Body == nil {
+		return r
+	}
+	var interval time.Duration
+	if r.cli != nil {
+		interval = r.cli.progressInterval
+	}
+	r.res.Body = newProgressReader(r.res.Body, r.res.ContentLength, interval, f)
+	return r
+}
+
 func (r *response) Close() error {
 	if r.res != nil && r.res.Body != nil {
 		return r.res.Body.Close()
@@ -85,7 +128,7 @@ func (r *response) decode(v interface{}, decoder Decoder) error {
 	if r.err != nil {
 		return r.err
 	}
-	if decoder == nil {
+	if decoder == nil && r.cli != nil {
 		decoder = r.cli.decoder
 	}
 	return decodeBody(decoder, r.res.Body, v)
@@ -99,6 +142,49 @@ func (r *response) Decode(v interface{}, decoder ...Decoder) error {
 	return r.decode(v, d)
 }
 
+// DecodeResult decodes a 2xx response into ok and any other into failed,
+// returning a *StatusError carrying failed as its Value in the latter case.
+func (r *response) DecodeResult(ok interface{}, failed interface{}, decoder ...Decoder) error {
+	var se *StatusError
+	if r.err != nil && (r.res == nil || !errors.As(r.err, &se)) {
+		r.Close()
+		return r.err
+	}
+	if r.res.StatusCode >= 200 && r.res.StatusCode <= 299 {
+		if ok == nil {
+			r.Close()
+			return nil
+		}
+		return r.Decode(ok, decoder...)
+	}
+	defer r.Close()
+	body, err := _ReadAll(r.res.Body)
+	if se == nil {
+		se = NewStatusError(r.res, nil)
+	}
+	se.Body = body
+	if len(body) > maxStatusErrorBody {
+		se.Body = body[:maxStatusErrorBody]
+	}
+	if err != nil {
+		return err
+	}
+	if failed != nil && len(body) > 0 {
+		var d Decoder
+		if len(decoder) > 0 {
+			d = decoder[0]
+		}
+		if d == nil {
+			d = decoderOf(r)
+		}
+		if err := decodeBody(d, _NopCloser(bytes.NewReader(body)), failed); err != nil {
+			return err
+		}
+		se.Value = failed
+	}
+	return se
+}
+
 func (r *response) String() (string, error) {
 	var d string
 	err := r.decode(&d, nil)
@@ -130,7 +216,7 @@ func (r *response) File(name string, perm os.FileMode) (int64, error) {
 	if r.err != nil || r.res == nil {
 		return 0, r.err
 	}
-	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, perm)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
 	if err != nil {
 		return 0, err
 	}
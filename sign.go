@@ -0,0 +1,351 @@
+package xhttp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSignatureMissing = errors.New("signature missing")
+	ErrSignatureInvalid = errors.New("signature invalid")
+	ErrSignatureExpired = errors.New("signature expired")
+)
+
+var defaultHMACSignHeaders = []string{"host", "date", "content-type", "x-content-sha256"}
+
+type HMACSignConfig struct {
+	KeyID  string
+	Secret []byte
+
+	// Optional. Default value "HMAC-SHA256".
+	Algorithm string
+
+	// Optional. Default value sha256.New.
+	Hash func() hash.Hash
+
+	// Optional. Default value "host", "date", "content-type" and "x-content-sha256".
+	// Missing headers are signed as empty values.
+	Headers []string
+
+	Now func() time.Time
+}
+
+func (config *HMACSignConfig) defaults() {
+	if len(config.Algorithm) == 0 {
+		config.Algorithm = "HMAC-SHA256"
+	}
+	if config.Hash == nil {
+		config.Hash = sha256.New
+	}
+	if len(config.Headers) == 0 {
+		config.Headers = defaultHMACSignHeaders
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+}
+
+// HMACSignInterceptor signs requests with
+//
+//	Authorization: HMAC-SHA256 KeyId="id", SignedHeaders="host;date", Signature="base64"
+//
+// over the canonical string built by CanonicalHMACString.
+func HMACSignInterceptor(config HMACSignConfig) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	config.defaults()
+	return func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			r := req.Request()
+			body, err := readRequestBody(r)
+			if err != nil {
+				return nil, err
+			}
+			if len(r.Header.Get("Date")) == 0 {
+				r.Header.Set("Date", config.Now().UTC().Format(http.TimeFormat))
+			}
+			r.Header.Set("X-Content-Sha256", hexSHA256(body))
+			headers := make([]string, len(config.Headers))
+			for i, h := range config.Headers {
+				headers[i] = strings.ToLower(h)
+			}
+			s := CanonicalHMACString(r, headers, body)
+			sig := hmacSum(config.Hash, config.Secret, []byte(s))
+			r.Header.Set("Authorization", config.Algorithm+
+				` KeyId="`+config.KeyID+
+				`", SignedHeaders="`+strings.Join(headers, ";")+
+				`", Signature="`+base64.StdEncoding.EncodeToString(sig)+`"`)
+			return next(req)
+		}
+	}
+}
+
+// CanonicalHMACString is
+//
+//	METHOD \n PATH \n CANONICAL-QUERY \n name:value \n ... \n HEX(SHA256(BODY))
+func CanonicalHMACString(r *http.Request, headers []string, body []byte) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte('\n')
+	b.WriteString(r.URL.EscapedPath())
+	b.WriteByte('\n')
+	b.WriteString(canonicalQuery(r.URL))
+	b.WriteByte('\n')
+	for _, h := range headers {
+		b.WriteString(h)
+		b.WriteByte(':')
+		b.WriteString(canonicalHeaderValue(r, h))
+		b.WriteByte('\n')
+	}
+	b.WriteString(hexSHA256(body))
+	return b.String()
+}
+
+type HMACVerifyConfig struct {
+	Skipper
+
+	// Secret returns the secret of a key id.
+	Secret func(keyID string) ([]byte, error)
+
+	// Optional. Default value "HMAC-SHA256".
+	Algorithm string
+
+	// Optional. Default value sha256.New.
+	Hash func() hash.Hash
+
+	// Optional. Default value "host" and "date", which must be signed.
+	RequiredHeaders []string
+
+	// Optional. Default value 5 minutes.
+	MaxSkew time.Duration
+
+	Now func() time.Time
+
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+func HMACVerifyWithConfig(config HMACVerifyConfig) func(h http.Handler) http.Handler {
+	if config.Skipper == nil {
+		config.Skipper = DefaultSkipper
+	}
+	if config.Secret == nil {
+		panic("hmac verify requires a secret func")
+	}
+	if len(config.Algorithm) == 0 {
+		config.Algorithm = "HMAC-SHA256"
+	}
+	if config.Hash == nil {
+		config.Hash = sha256.New
+	}
+	if len(config.RequiredHeaders) == 0 {
+		config.RequiredHeaders = []string{"host", "date"}
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = 5 * time.Minute
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	verify := func(r *http.Request) error {
+		params, ok := parseAuthorization(r.Header.Get("Authorization"), config.Algorithm)
+		if !ok {
+			return ErrSignatureMissing
+		}
+		headers := strings.Split(params["signedheaders"], ";")
+		for _, h := range config.RequiredHeaders {
+			if !containsFold(headers, h) {
+				return ErrSignatureInvalid
+			}
+		}
+		date, err := http.ParseTime(r.Header.Get("Date"))
+		if err != nil {
+			return ErrSignatureInvalid
+		}
+		if d := config.Now().Sub(date); d > config.MaxSkew || d < -config.MaxSkew {
+			return ErrSignatureExpired
+		}
+		secret, err := config.Secret(params["keyid"])
+		if err != nil {
+			return err
+		}
+		sig, err := base64.StdEncoding.DecodeString(params["signature"])
+		if err != nil {
+			return ErrSignatureInvalid
+		}
+		body, err := readServerBody(r)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(r.Header.Get("X-Content-Sha256"), hexSHA256(body)) {
+			return ErrSignatureInvalid
+		}
+		want := hmacSum(config.Hash, secret, []byte(CanonicalHMACString(r, headers, body)))
+		if !hmac.Equal(sig, want) {
+			return ErrSignatureInvalid
+		}
+		return nil
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.Skipper(w, r) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if err := verify(r); err != nil {
+				handleAuthError(w, r, config.ErrorHandler, err)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func handleAuthError(w http.ResponseWriter, r *http.Request, eh func(w http.ResponseWriter, r *http.Request, err error), err error) {
+	if eh != nil {
+		eh(w, r, err)
+		return
+	}
+	if router := LookupRouter(r); router != nil {
+		router.HandleError(w, r, NewHttpError(http.StatusUnauthorized, err.Error()))
+		return
+	}
+	WriteError(w, http.StatusUnauthorized, err.Error())
+}
+
+// readServerBody reads the whole body of an incoming request and puts it back.
+func readServerBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := _ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = _NopCloser(bytes.NewReader(b))
+	return b, err
+}
+
+// parseAuthorization parses `Scheme k1="v1", k2=v2` into lower cased keys.
+func parseAuthorization(s string, scheme string) (map[string]string, bool) {
+	if len(s) <= len(scheme) || !strings.EqualFold(s[:len(scheme)], scheme) || s[len(scheme)] != ' ' {
+		return nil, false
+	}
+	params := map[string]string{}
+	for _, kv := range splitAuthParams(s[len(scheme)+1:]) {
+		p := strings.Index(kv, "=")
+		if p < 0 {
+			continue
+		}
+		k := strings.ToLower(strings.TrimSpace(kv[:p]))
+		v := strings.TrimSpace(kv[p+1:])
+		if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+			v = strings.Replace(v[1:len(v)-1], `\"`, `"`, -1)
+		}
+		params[k] = v
+	}
+	return params, true
+}
+
+// splitAuthParams splits on commas outside of quoted strings.
+func splitAuthParams(s string) []string {
+	var parts []string
+	var quoted bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			if i == 0 || s[i-1] != '\\' {
+				quoted = !quoted
+			}
+		case ',':
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		vs := append([]string(nil), query[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(uriEncode(k, true))
+			b.WriteByte('=')
+			b.WriteString(uriEncode(v, true))
+		}
+	}
+	return b.String()
+}
+
+func canonicalHeaderValue(r *http.Request, name string) string {
+	if strings.EqualFold(name, "host") {
+		if len(r.Host) > 0 {
+			return r.Host
+		}
+		return r.URL.Host
+	}
+	vs := r.Header[http.CanonicalHeaderKey(name)]
+	values := make([]string, len(vs))
+	for i, v := range vs {
+		values[i] = strings.Join(strings.Fields(v), " ")
+	}
+	return strings.Join(values, ",")
+}
+
+// uriEncode escapes everything but the unreserved characters of RFC 3986,
+// keeping "/" when encodeSlash is false.
+func uriEncode(s string, encodeSlash bool) string {
+	const hexUpper = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexUpper[c>>4])
+		b.WriteByte(hexUpper[c&15])
+	}
+	return b.String()
+}
+
+func containsFold(ss []string, s string) bool {
+	for _, v := range ss {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func hmacSum(h func() hash.Hash, key []byte, data []byte) []byte {
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func hexSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,110 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACSign(t *testing.T) {
+	secret := func(keyID string) ([]byte, error) {
+		if keyID != "k1" {
+			return nil, errors.New("unknown key")
+		}
+		return []byte("s3cret"), nil
+	}
+	verify := HMACVerifyWithConfig(HMACVerifyConfig{Secret: secret})
+	srv := httptest.NewServer(verify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := _ReadAll(r.Body)
+		w.Write(b)
+	})))
+	defer srv.Close()
+
+	c := NewClient().Interceptor(HMACSignInterceptor(HMACSignConfig{KeyID: "k1", Secret: []byte("s3cret")}))
+	b, err := c.Post(context.TODO(), srv.URL+"/a/b?y=2&x=1", `{"a":1}`).JSON().Do().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":1}` {
+		t.Fatal("unexpected body", string(b))
+	}
+
+	// signing reads the body without reporting upload progress
+	for _, body := range []interface{}{"abc", strings.NewReader("abc")} {
+		var sent []int64
+		progress := func(n int64, total int64) { sent = append(sent, n) }
+		if _, err := c.Post(context.TODO(), srv.URL, body).UploadProgress(progress).Do().Bytes(); err != nil {
+			t.Fatal(err)
+		}
+		if len(sent) == 0 || len(sent) > 2 || sent[0] != 3 {
+			t.Fatal("unexpected progress", sent)
+		}
+	}
+
+	c = NewClient().Interceptor(HMACSignInterceptor(HMACSignConfig{KeyID: "k1", Secret: []byte("wrong")}))
+	resp := c.Get(context.TODO(), srv.URL).Do()
+	if err := resp.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if code := resp.Response().StatusCode; code != http.StatusUnauthorized {
+		t.Fatal("want 401, got", code)
+	}
+
+	c = NewClient().Interceptor(HMACSignInterceptor(HMACSignConfig{KeyID: "k1", Secret: []byte("s3cret"),
+		Now: func() time.Time { return time.Now().Add(-time.Hour) }}))
+	resp = c.Get(context.TODO(), srv.URL).Do()
+	if code := resp.Response().StatusCode; code != http.StatusUnauthorized {
+		t.Fatal("want 401, got", code)
+	}
+}
+
+func TestSigV4(t *testing.T) {
+	// get-vanilla from the AWS Signature Version 4 test suite
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	now, _ := time.Parse(sigV4TimeFormat, "20150830T123600Z")
+	err := SignSigV4(r, hexSHA256(nil), SigV4Config{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+		Now:             func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := r.Header.Get("Authorization"); got != want {
+		t.Fatal("unexpected authorization", got)
+	}
+
+	verify := SigV4VerifyWithConfig(SigV4VerifyConfig{
+		Region:  "us-east-1",
+		Service: "service",
+		SecretAccessKey: func(akid string) (string, error) {
+			return "secret", nil
+		},
+	})
+	srv := httptest.NewServer(verify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer srv.Close()
+
+	c := NewClient().Interceptor(SigV4Interceptor(SigV4Config{AccessKeyID: "id", SecretAccessKey: "secret", Region: "us-east-1", Service: "service"}))
+	resp := c.Put(context.TODO(), srv.URL+"/a%20b/c?k=v", strings.NewReader("data")).Do()
+	if err := resp.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if code := resp.Response().StatusCode; code != http.StatusOK {
+		t.Fatal("want 200, got", code)
+	}
+
+	c = NewClient().Interceptor(SigV4Interceptor(SigV4Config{AccessKeyID: "id", SecretAccessKey: "other", Region: "us-east-1", Service: "service"}))
+	resp = c.Get(context.TODO(), srv.URL).Do()
+	if code := resp.Response().StatusCode; code != http.StatusUnauthorized {
+		t.Fatal("want 401, got", code)
+	}
+}
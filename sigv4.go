@@ -0,0 +1,212 @@
+package xhttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+var sigV4IgnoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+	"content-length":  true,
+}
+
+type SigV4Config struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+
+	// UnsignedPayload signs "UNSIGNED-PAYLOAD" instead of the body hash, as allowed by S3.
+	UnsignedPayload bool
+
+	Now func() time.Time
+}
+
+// SigV4Interceptor signs requests with AWS Signature Version 4, covering
+// the host and all headers of the request.
+func SigV4Interceptor(config SigV4Config) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			r := req.Request()
+			payloadHash := sigV4UnsignedPayload
+			if !config.UnsignedPayload {
+				body, err := readRequestBody(r)
+				if err != nil {
+					return nil, err
+				}
+				payloadHash = hexSHA256(body)
+			}
+			if err := SignSigV4(r, payloadHash, config); err != nil {
+				return nil, err
+			}
+			return next(req)
+		}
+	}
+}
+
+// SignSigV4 sets the X-Amz-Date and Authorization headers of r.
+func SignSigV4(r *http.Request, payloadHash string, config SigV4Config) error {
+	now := time.Now
+	if config.Now != nil {
+		now = config.Now
+	}
+	amzDate := now().UTC().Format(sigV4TimeFormat)
+	r.Header.Set("X-Amz-Date", amzDate)
+	if len(config.SessionToken) > 0 {
+		r.Header.Set("X-Amz-Security-Token", config.SessionToken)
+	}
+	if config.Service == "s3" || config.UnsignedPayload {
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	headers := []string{"host"}
+	for k := range r.Header {
+		if k = strings.ToLower(k); !sigV4IgnoredHeaders[k] {
+			headers = append(headers, k)
+		}
+	}
+	sort.Strings(headers)
+	scope := amzDate[:8] + "/" + config.Region + "/" + config.Service + "/aws4_request"
+	sig := sigV4Signature(r, headers, payloadHash, amzDate, scope, config.SecretAccessKey, config.Region, config.Service)
+	r.Header.Set("Authorization", sigV4Algorithm+
+		" Credential="+config.AccessKeyID+"/"+scope+
+		", SignedHeaders="+strings.Join(headers, ";")+
+		", Signature="+sig)
+	return nil
+}
+
+func sigV4Signature(r *http.Request, headers []string, payloadHash, amzDate, scope, secret, region, service string) string {
+	path := r.URL.EscapedPath()
+	if service != "s3" {
+		path = uriEncode(path, false)
+	}
+	if len(path) == 0 {
+		path = "/"
+	}
+	var cr strings.Builder
+	cr.WriteString(r.Method + "\n")
+	cr.WriteString(path + "\n")
+	cr.WriteString(canonicalQuery(r.URL) + "\n")
+	for _, h := range headers {
+		cr.WriteString(h + ":" + canonicalHeaderValue(r, h) + "\n")
+	}
+	cr.WriteString("\n")
+	cr.WriteString(strings.Join(headers, ";") + "\n")
+	cr.WriteString(payloadHash)
+
+	stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(cr.String()))
+
+	key := hmacSum(sha256.New, []byte("AWS4"+secret), []byte(amzDate[:8]))
+	key = hmacSum(sha256.New, key, []byte(region))
+	key = hmacSum(sha256.New, key, []byte(service))
+	key = hmacSum(sha256.New, key, []byte("aws4_request"))
+	return hex.EncodeToString(hmacSum(sha256.New, key, []byte(stringToSign)))
+}
+
+type SigV4VerifyConfig struct {
+	Skipper
+
+	Region  string
+	Service string
+
+	// SecretAccessKey returns the secret of an access key id.
+	SecretAccessKey func(accessKeyID string) (string, error)
+
+	// Optional. Default value 5 minutes.
+	MaxSkew time.Duration
+
+	Now func() time.Time
+
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+func SigV4VerifyWithConfig(config SigV4VerifyConfig) func(h http.Handler) http.Handler {
+	if config.Skipper == nil {
+		config.Skipper = DefaultSkipper
+	}
+	if config.SecretAccessKey == nil {
+		panic("sigv4 verify requires a secret access key func")
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = 5 * time.Minute
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	verify := func(r *http.Request) error {
+		params, ok := parseAuthorization(r.Header.Get("Authorization"), sigV4Algorithm)
+		if !ok {
+			return ErrSignatureMissing
+		}
+		credential := strings.SplitN(params["credential"], "/", 2)
+		if len(credential) != 2 {
+			return ErrSignatureInvalid
+		}
+		amzDate := r.Header.Get("X-Amz-Date")
+		t, err := time.Parse(sigV4TimeFormat, amzDate)
+		if err != nil {
+			return ErrSignatureInvalid
+		}
+		if d := config.Now().Sub(t); d > config.MaxSkew || d < -config.MaxSkew {
+			return ErrSignatureExpired
+		}
+		scope := amzDate[:8] + "/" + config.Region + "/" + config.Service + "/aws4_request"
+		if credential[1] != scope {
+			return ErrSignatureInvalid
+		}
+		headers := strings.Split(params["signedheaders"], ";")
+		if !containsFold(headers, "host") {
+			return ErrSignatureInvalid
+		}
+		secret, err := config.SecretAccessKey(credential[0])
+		if err != nil {
+			return err
+		}
+		payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+		if payloadHash != sigV4UnsignedPayload {
+			body, err := readServerBody(r)
+			if err != nil {
+				return err
+			}
+			if len(payloadHash) > 0 && payloadHash != hexSHA256(body) {
+				return ErrSignatureInvalid
+			}
+			payloadHash = hexSHA256(body)
+		}
+		want := sigV4Signature(r, headers, payloadHash, amzDate, scope, secret, config.Region, config.Service)
+		if !hmac.Equal([]byte(params["signature"]), []byte(want)) {
+			return ErrSignatureInvalid
+		}
+		return nil
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.Skipper(w, r) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if err := verify(r); err != nil {
+				handleAuthError(w, r, config.ErrorHandler, err)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
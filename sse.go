@@ -0,0 +1,258 @@
+package xhttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a message of a text/event-stream.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+type EventsConfig struct {
+	// NoReconnect stops at the end of the stream instead of reconnecting.
+	NoReconnect bool
+
+	// MaxReconnects limits consecutive reconnects without receiving an
+	// event, 0 means unlimited.
+	MaxReconnects int
+
+	// Optional. Default value 3 seconds, the server may change it with "retry".
+	Retry time.Duration
+
+	OnComment func(comment string)
+
+	// Optional. Default value 1 MB.
+	MaxLineSize int
+}
+
+// Events calls f for every event of a text/event-stream response. When the
+// stream ends it reconnects with the Last-Event-ID header until f returns an
+// error, the server answers 204 or the request context is done.
+func (r *response) Events(f func(e *Event) error, config ...EventsConfig) error {
+	var cfg EventsConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Retry <= 0 {
+		cfg.Retry = 3 * time.Second
+	}
+	if cfg.MaxLineSize <= 0 {
+		cfg.MaxLineSize = 1 << 20
+	}
+	if r.err != nil {
+		r.Close()
+		return r.err
+	}
+	s := &eventStream{config: cfg, f: f, retry: cfg.Retry}
+	req := r.origin
+	if req == nil {
+		req = r.res.Request
+	}
+	ctx := r.context()
+	res := r.res
+	attempts := 0
+	for {
+		if res.StatusCode == http.StatusNoContent {
+			res.Body.Close()
+			return nil
+		}
+		if err := checkEventStream(res); err != nil {
+			return err
+		}
+		received, err := s.read(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if received {
+			attempts = 0
+		}
+		if cfg.NoReconnect || req == nil {
+			return io.EOF
+		}
+
+		// failed reconnects back off from the retry interval of the server
+		delay := s.retry
+		var lastErr error
+		for {
+			attempts++
+			if cfg.MaxReconnects > 0 && attempts > cfg.MaxReconnects {
+				if lastErr != nil {
+					return lastErr
+				}
+				return io.EOF
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			next, err := r.reconnect(ctx, req, s.lastEventID)
+			if err == nil {
+				res = next
+				break
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = err
+			if delay *= 2; delay > maxEventsBackoff {
+				delay = maxEventsBackoff
+			}
+		}
+	}
+}
+
+const maxEventsBackoff = time.Minute
+
+func (r *response) reconnect(ctx context.Context, req *http.Request, lastEventID string) (*http.Response, error) {
+	cli := r.cli
+	if cli == nil {
+		cli = &DefaultClient
+	}
+	var body io.ReadCloser
+	if req.GetBody != nil {
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	nr := cli.Request(ctx, req.Method, req.URL.String(), body)
+	for k, vs := range req.Header {
+		for _, v := range vs {
+			nr.AddHeader(k, v)
+		}
+	}
+	if len(lastEventID) > 0 {
+		nr.SetHeader("Last-Event-ID", lastEventID)
+	}
+	resp := nr.Do()
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+	return resp.Response(), nil
+}
+
+func checkEventStream(res *http.Response) error {
+	if res.StatusCode != http.StatusOK {
+		b, _ := peekBody(res, maxStatusErrorBody)
+		res.Body.Close()
+		return NewStatusError(res, b)
+	}
+	if mt, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mt != "text/event-stream" {
+		res.Body.Close()
+		return &StatusError{StatusCode: res.StatusCode, Status: "unexpected content type " + res.Header.Get("Content-Type"), Header: res.Header}
+	}
+	return nil
+}
+
+type eventStream struct {
+	config EventsConfig
+	f      func(e *Event) error
+
+	lastEventID string
+	retry       time.Duration
+}
+
+// read parses events until the body ends, returning the error of f.
+func (s *eventStream) read(body io.Reader) (received bool, err error) {
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 0, 4096), s.config.MaxLineSize)
+	sc.Split(scanEventLines)
+
+	var data bytes.Buffer
+	var event string
+	first := true
+	for sc.Scan() {
+		line := sc.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+		if len(line) == 0 {
+			if data.Len() > 0 {
+				d := data.Bytes()
+				e := &Event{ID: s.lastEventID, Event: event, Data: string(d[:len(d)-1])}
+				if len(e.Event) == 0 {
+					e.Event = "message"
+				}
+				received = true
+				if err := s.f(e); err != nil {
+					return received, err
+				}
+			}
+			data.Reset()
+			event = ""
+			continue
+		}
+		if line[0] == ':' {
+			if s.config.OnComment != nil {
+				s.config.OnComment(strings.TrimPrefix(line[1:], " "))
+			}
+			continue
+		}
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	// an error ends the stream like EOF does, the pending event is dropped
+	return received, nil
+}
+
+// scanEventLines splits lines ended by "\r\n", "\r" or "\n".
+func scanEventLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
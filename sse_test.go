@@ -0,0 +1,109 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	var conns int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch atomic.AddInt32(&conns, 1) {
+		case 1:
+			w.Write([]byte(": hello\r\nretry: 10\r\n\r\nid: 1\r\ndata: a\r\ndata: b\r\n\r\nevent: ping\ndata\n\n"))
+		case 2:
+			w.Write([]byte("id: 2\rdata: " + r.Header.Get("Last-Event-ID") + "\r\r"))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	var events []Event
+	var comments []string
+	err := NewClient().Get(context.TODO(), srv.URL).Do().Events(func(e *Event) error {
+		events = append(events, *e)
+		return nil
+	}, EventsConfig{OnComment: func(c string) { comments = append(comments, c) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{{ID: "1", Event: "message", Data: "a\nb"}, {ID: "1", Event: "ping", Data: ""}, {ID: "2", Event: "message", Data: "1"}}
+	if len(events) != len(want) || len(comments) != 1 || comments[0] != "hello" {
+		t.Fatal("unexpected events", events, comments)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatal("unexpected event", events[i], want[i])
+		}
+	}
+}
+
+func TestEventsCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: x\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	err := NewClient().Get(ctx, srv.URL).Do().Events(func(e *Event) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("want context.Canceled, got", err)
+	}
+
+	stop := errors.New("stop")
+	err = NewClient().Get(context.TODO(), srv.URL).Do().Events(func(e *Event) error { return stop })
+	if err != stop {
+		t.Fatal("want stop, got", err)
+	}
+}
+
+func TestEventsReconnectWithTimeout(t *testing.T) {
+	var conns int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&conns, 1) {
+		case 1:
+			// a fresh connection is not retried by the transport
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("retry: 5\nid: 1\ndata: a\n\n"))
+		case 2:
+			// drop the connection, the reconnect fails and is retried
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		case 3:
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: " + r.Header.Get("Last-Event-ID") + "\n\n"))
+		case 4:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	var data []string
+	c := NewClient().Timeout(5 * time.Second)
+	err := c.Get(context.TODO(), srv.URL).Do().Events(func(e *Event) error {
+		data = append(data, e.Data)
+		return nil
+	})
+	if err != nil || len(data) != 2 || data[1] != "1" || atomic.LoadInt32(&conns) != 4 {
+		t.Fatal("unexpected reconnects", data, err, conns)
+	}
+
+	atomic.StoreInt32(&conns, 0)
+	err = c.Get(context.TODO(), srv.URL).Do().Events(func(e *Event) error { return nil }, EventsConfig{Retry: time.Millisecond, MaxReconnects: 1})
+	if err == nil || err == io.EOF {
+		t.Fatal("want the reconnect error, got", err)
+	}
+}
@@ -0,0 +1,159 @@
+package xhttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const maxStatusErrorBody = 4 << 10
+
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+
+	// Body is the beginning of the response body.
+	Body []byte
+
+	// Value is the response body decoded into the error type of ExpectStatusConfig.
+	Value interface{}
+}
+
+func NewStatusError(res *http.Response, body []byte) *StatusError {
+	return &StatusError{StatusCode: res.StatusCode, Status: res.Status, Header: res.Header, Body: body}
+}
+
+func (e *StatusError) Error() string {
+	s := "wrong status code: " + strconv.FormatInt(int64(e.StatusCode), 10)
+	if err, ok := e.Value.(error); ok {
+		s += ": " + err.Error()
+	}
+	return s
+}
+
+// Unwrap returns the decoded body when it is an error itself, so it can be matched with errors.As.
+func (e *StatusError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+func StatusCodeOf(err error) int {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode
+	}
+	return 0
+}
+
+func IsStatus(err error, codes ...int) bool {
+	code := StatusCodeOf(err)
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func IsNotFound(err error) bool {
+	return IsStatus(err, http.StatusNotFound)
+}
+
+func IsUnauthorized(err error) bool {
+	return IsStatus(err, http.StatusUnauthorized)
+}
+
+func IsForbidden(err error) bool {
+	return IsStatus(err, http.StatusForbidden)
+}
+
+func IsClientError(err error) bool {
+	code := StatusCodeOf(err)
+	return code >= 400 && code <= 499
+}
+
+func IsServerError(err error) bool {
+	code := StatusCodeOf(err)
+	return code >= 500 && code <= 599
+}
+
+type ExpectStatusConfig struct {
+	// Optional. Default any 2xx status.
+	Codes []int
+
+	// Optional. Default value 4 KB.
+	MaxBodySize int
+
+	// NewError returns a pointer the error body is decoded into with the client decoder.
+	NewError func() interface{}
+}
+
+func (c Client) ExpectStatus(codes ...int) Client {
+	return c.Interceptor(ExpectStatusWithConfig(ExpectStatusConfig{Codes: codes}))
+}
+
+// ExpectStatusWithConfig fails the call with a *StatusError on an unexpected
+// status. The response body is left intact for the caller.
+func ExpectStatusWithConfig(config ExpectStatusConfig) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = maxStatusErrorBody
+	}
+	return func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			resp, err := next(req)
+			if err != nil {
+				return resp, err
+			}
+			res := resp.Response()
+			if expectedStatus(res.StatusCode, config.Codes) {
+				return resp, nil
+			}
+			body, _ := peekBody(res, config.MaxBodySize)
+			se := NewStatusError(res, body)
+			if config.NewError != nil && len(body) > 0 {
+				v := config.NewError()
+				if decodeBody(decoderOf(resp), _NopCloser(bytes.NewReader(body)), v) == nil {
+					se.Value = v
+				}
+			}
+			return resp, se
+		}
+	}
+}
+
+func expectedStatus(code int, codes []int) bool {
+	if len(codes) == 0 {
+		return code >= 200 && code <= 299
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// peekBody reads up to n bytes of the body and puts them back in front of it.
+func peekBody(res *http.Response, n int) ([]byte, error) {
+	if res.Body == nil || res.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := _ReadAll(io.LimitReader(res.Body, int64(n)))
+	res.Body = &struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), res.Body), res.Body}
+	return b, err
+}
+
+func decoderOf(resp Response) Decoder {
+	if r, ok := resp.(*response); ok && r.cli != nil && r.cli.decoder != nil {
+		return r.cli.decoder
+	}
+	return JSON
+}
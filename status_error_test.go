@@ -0,0 +1,83 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func TestExpectStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			WriteString(w, http.StatusOK, "", "ok")
+		case "/missing":
+			WriteJSON(w, http.StatusNotFound, &apiError{Code: "not_found", Message: "no such user"})
+		default:
+			WriteString(w, http.StatusBadGateway, "", "bad gateway")
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient().Interceptor(ExpectStatusWithConfig(ExpectStatusConfig{
+		NewError: func() interface{} { return new(apiError) },
+	}))
+
+	if s, err := c.Get(context.TODO(), srv.URL+"/ok").Do().String(); err != nil || s != "ok" {
+		t.Fatal(s, err)
+	}
+
+	resp := c.Get(context.TODO(), srv.URL+"/missing").Do()
+	err := resp.Error()
+	var se *StatusError
+	var ae *apiError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound || !IsNotFound(err) || IsServerError(err) {
+		t.Fatal("want not found, got", err)
+	}
+	if !errors.As(err, &ae) || ae.Code != "not_found" {
+		t.Fatal("want decoded api error, got", err)
+	}
+	if b, _ := _ReadAll(resp.Response().Body); len(b) == 0 || string(b) != string(se.Body) {
+		t.Fatal("want body left intact", string(b))
+	}
+
+	err = NewClient().ExpectStatus(http.StatusOK).Get(context.TODO(), srv.URL+"/fail").Do().Error()
+	if !IsServerError(err) || StatusCodeOf(err) != http.StatusBadGateway || string(err.(*StatusError).Body) != "bad gateway" {
+		t.Fatal("want bad gateway, got", err)
+	}
+}
+
+func TestDecodeResult(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			WriteJSON(w, http.StatusOK, &Person{Name: "john", Age: 12})
+			return
+		}
+		WriteJSON(w, http.StatusConflict, &apiError{Code: "conflict", Message: "exists"})
+	}))
+	defer srv.Close()
+
+	for _, c := range []Client{NewClient(), NewClient().ExpectStatus()} {
+		var p Person
+		var ae apiError
+		if err := c.Get(context.TODO(), srv.URL+"/ok").Do().DecodeResult(&p, &ae); err != nil || p.Name != "john" {
+			t.Fatal(p, err)
+		}
+		err := c.Get(context.TODO(), srv.URL+"/fail").Do().DecodeResult(&p, &ae)
+		var target *apiError
+		if !IsStatus(err, http.StatusConflict) || !errors.As(err, &target) || target.Code != "conflict" {
+			t.Fatal("want conflict, got", err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package xhttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// NDJSON decodes every line of a newline delimited JSON body into v and
+// calls f, until the body ends, f returns an error or the context is done.
+//
+//	var item Item
+//	err := resp.NDJSON(&item, func() error { ...; return nil })
+func (r *response) NDJSON(v interface{}, f func() error) error {
+	defer r.Close()
+	if r.err != nil {
+		return r.err
+	}
+	ctx := r.context()
+	dec := json.NewDecoder(r.res.Body)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		resetValue(v)
+		if err := dec.Decode(v); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return r.streamError(ctx, err)
+		}
+		if err := f(); err != nil {
+			return err
+		}
+	}
+}
+
+// JSONArray decodes the elements of a top level JSON array one by one into
+// v and calls f, without reading the whole array into memory.
+func (r *response) JSONArray(v interface{}, f func() error) error {
+	defer r.Close()
+	if r.err != nil {
+		return r.err
+	}
+	ctx := r.context()
+	dec := json.NewDecoder(r.res.Body)
+	tok, err := dec.Token()
+	if err != nil {
+		return r.streamError(ctx, err)
+	}
+	if tok == nil {
+		return nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return fmt.Errorf("json: want array, got %v", tok)
+	}
+	for dec.More() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		resetValue(v)
+		if err := dec.Decode(v); err != nil {
+			return r.streamError(ctx, err)
+		}
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return r.streamError(ctx, err)
+	}
+	return nil
+}
+
+func (r *response) context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	if r.res.Request != nil {
+		return r.res.Request.Context()
+	}
+	return context.Background()
+}
+
+// streamError prefers the context error, a canceled read surfaces as a decode error.
+func (r *response) streamError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// resetValue zeroes the target of v, so fields of the previous element do not leak.
+func resetValue(v interface{}) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+	}
+}
@@ -0,0 +1,70 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type streamItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+func TestNDJSONAndJSONArray(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ndjson":
+			w.Write([]byte("{\"id\":1,\"name\":\"a\"}\n\n{\"id\":2}\n"))
+		case "/array":
+			w.Write([]byte(` [{"id":1,"name":"a"}, {"id":2}] `))
+		case "/slow":
+			w.Write([]byte("{\"id\":1}\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	for _, path := range []string{"/ndjson", "/array"} {
+		var item streamItem
+		var items []streamItem
+		collect := func() error {
+			items = append(items, item)
+			return nil
+		}
+		resp := c.Get(context.TODO(), srv.URL+path).Do()
+		var err error
+		if path == "/ndjson" {
+			err = resp.NDJSON(&item, collect)
+		} else {
+			err = resp.JSONArray(&item, collect)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 2 || items[0] != (streamItem{1, "a"}) || items[1] != (streamItem{ID: 2}) {
+			t.Fatal("unexpected items", path, items)
+		}
+	}
+
+	var item streamItem
+	if err := c.Get(context.TODO(), srv.URL+"/ndjson").Do().JSONArray(&item, func() error { return nil }); err == nil {
+		t.Fatal("want error for non array body")
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	n := 0
+	err := c.Get(ctx, srv.URL+"/slow").Do().NDJSON(&item, func() error {
+		n++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return nil
+	})
+	if n != 1 || !errors.Is(err, context.Canceled) {
+		t.Fatal("want context.Canceled, got", n, err)
+	}
+}
@@ -0,0 +1,209 @@
+package xhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+type Timeouts struct {
+	Connect        time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+
+	// Total bounds the whole call, including reading the response body.
+	Total time.Duration
+}
+
+func (c Client) Timeouts(t Timeouts) Client {
+	c.timeouts = t
+	return c
+}
+
+func (c Client) Timeout(total time.Duration) Client {
+	c.timeouts.Total = total
+	return c
+}
+
+type TimeoutError struct {
+	Op       string
+	Duration time.Duration
+	Err      error
+}
+
+func (e *TimeoutError) Error() string {
+	s := e.Op + " timeout after " + e.Duration.String()
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+func (e *TimeoutError) Temporary() bool {
+	return true
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// IsTimeout reports whether err is a timeout, either a *TimeoutError
+// or any error in the chain with a Timeout() method returning true.
+func IsTimeout(err error) bool {
+	var te *TimeoutError
+	if errors.As(err, &te) {
+		return true
+	}
+	var ne interface{ Timeout() bool }
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	t := c.timeouts
+	if t.Connect <= 0 && t.TLSHandshake <= 0 && t.ResponseHeader <= 0 {
+		return c.cli.Do(req)
+	}
+	pt := newPhaseTimer(req.Context(), t)
+	res, err := c.cli.Do(req.WithContext(pt.ctx))
+	pt.stop()
+	if err != nil {
+		pt.cancel()
+		if te := pt.expiredError(); te != nil {
+			te.Err = err
+			return res, te
+		}
+		return res, err
+	}
+	OnBodyClose(res, pt.cancel)
+	return res, nil
+}
+
+// phaseTimer arms a timer around each phase of a round trip and cancels
+// the request context once one of them expires.
+type phaseTimer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	expired *TimeoutError
+}
+
+func newPhaseTimer(parent context.Context, t Timeouts) *phaseTimer {
+	p := &phaseTimer{}
+	p.ctx, p.cancel = context.WithCancel(parent)
+	p.ctx = httptrace.WithClientTrace(p.ctx, &httptrace.ClientTrace{
+		ConnectStart:         func(network, addr string) { p.start("connect", t.Connect) },
+		ConnectDone:          func(network, addr string, err error) { p.stop() },
+		TLSHandshakeStart:    func() { p.start("tls handshake", t.TLSHandshake) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { p.stop() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { p.start("response header", t.ResponseHeader) },
+		GotFirstResponseByte: func() { p.stop() },
+	})
+	return p
+}
+
+func (p *phaseTimer) start(op string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if d <= 0 || p.expired != nil {
+		return
+	}
+	p.timer = time.AfterFunc(d, func() {
+		p.mu.Lock()
+		p.expired = &TimeoutError{Op: op, Duration: d}
+		p.mu.Unlock()
+		p.cancel()
+	})
+}
+
+func (p *phaseTimer) stop() {
+	p.mu.Lock()
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+}
+
+func (p *phaseTimer) expiredError() *TimeoutError {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.expired
+}
+
+type totalTimeout struct {
+	parent  context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+func newTotalTimeout(parent context.Context, d time.Duration) *totalTimeout {
+	ctx, cancel := context.WithTimeout(parent, d)
+	return &totalTimeout{parent: parent, ctx: ctx, cancel: cancel, timeout: d}
+}
+
+func (t *totalTimeout) wrap(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	var te *TimeoutError
+	if errors.As(err, &te) {
+		return err
+	}
+	if t.ctx.Err() == context.DeadlineExceeded && t.parent.Err() == nil {
+		return &TimeoutError{Op: "total", Duration: t.timeout, Err: err}
+	}
+	return err
+}
+
+// done maps the outcome of a call onto the total deadline, releasing the
+// context once the response body has been closed.
+func (t *totalTimeout) done(resp Response, err error) error {
+	err = t.wrap(err)
+	var res *http.Response
+	if resp != nil {
+		res = resp.Response()
+	}
+	if res == nil || res.Body == nil || res.Body == http.NoBody {
+		t.cancel()
+		return err
+	}
+	// the body of an error response may still be read, e.g. by DecodeResult
+	res.Body = &totalTimeoutBody{ReadCloser: res.Body, t: t}
+	return err
+}
+
+type totalTimeoutBody struct {
+	io.ReadCloser
+	t *totalTimeout
+}
+
+func (b *totalTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	return n, b.t.wrap(err)
+}
+
+func (b *totalTimeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.t.cancel()
+	return err
+}
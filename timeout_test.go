@@ -0,0 +1,94 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/body" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	var te *TimeoutError
+
+	err := NewClient().Get(context.TODO(), srv.URL).Timeout(50 * time.Millisecond).Do().Error()
+	if !errors.As(err, &te) || te.Op != "total" || !IsTimeout(err) {
+		t.Fatal("want total timeout, got", err)
+	}
+
+	c := NewClient().Timeouts(Timeouts{ResponseHeader: 50 * time.Millisecond})
+	err = c.Get(context.TODO(), srv.URL).Do().Error()
+	if !errors.As(err, &te) || te.Op != "response header" {
+		t.Fatal("want response header timeout, got", err)
+	}
+
+	c = NewClient().Timeout(50 * time.Millisecond)
+	_, err = c.Get(context.TODO(), srv.URL+"/body").Do().Bytes()
+	if !errors.As(err, &te) || te.Op != "total" {
+		t.Fatal("want total timeout while reading body, got", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	err = c.Get(ctx, srv.URL).Do().Error()
+	if err == nil || errors.As(err, &te) {
+		t.Fatal("want canceled error, got", err)
+	}
+}
+
+func TestTimeoutDoesNotStickToRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	ctx := context.TODO()
+	c := NewClient().Timeout(5 * time.Second)
+	req := c.Get(ctx, srv.URL)
+	if s, err := req.Do().String(); err != nil || s != "ok" {
+		t.Fatal(s, err)
+	}
+	if req.Context() != ctx || req.Request().Context().Err() != nil {
+		t.Fatal("call context leaked into the request")
+	}
+	if s, err := req.Do().String(); err != nil || s != "ok" {
+		t.Fatal("request not reusable", s, err)
+	}
+}
+
+func TestTimeoutKeepsErrorBody(t *testing.T) {
+	body := strings.Repeat("x", 8<<10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		// the rest arrives after ExpectStatus peeked the body
+		w.Write([]byte(body[:5000]))
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(body[5000:]))
+	}))
+	defer srv.Close()
+
+	resp := NewClient().Timeout(5*time.Second).ExpectStatus().Get(context.TODO(), srv.URL).Do()
+	var se *StatusError
+	if !errors.As(resp.Error(), &se) {
+		t.Fatal("want *StatusError, got", resp.Error())
+	}
+	defer resp.Response().Body.Close()
+	if b, err := _ReadAll(resp.Response().Body); err != nil || string(b) != body {
+		t.Fatal("error body not readable", len(b), err)
+	}
+}
@@ -0,0 +1,106 @@
+package xhttp
+
+import (
+	"sync"
+	"time"
+)
+
+// Timings of a round trip. Phases that did not happen, such as DNS and
+// connect on a reused connection, are 0.
+type Timings struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+
+	// TTFB is the time from the start of the call to the first response byte.
+	TTFB time.Duration
+
+	// Total is the time until the response header is received, it is
+	// extended to the time the body is closed once it has been read.
+	Total time.Duration
+
+	Reused     bool
+	RemoteAddr string
+}
+
+// timingsHolder is updated once the body is closed.
+type timingsHolder struct {
+	mu sync.Mutex
+	t  Timings
+}
+
+func (h *timingsHolder) get() *Timings {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := h.t
+	return &t
+}
+
+func (h *timingsHolder) update(trace *roundTripTrace) {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := &h.t
+	positive := func(d time.Duration) time.Duration {
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+	t.DNS = positive(since(trace.dnsStart, trace.dnsDone))
+	t.Connect = positive(since(trace.connectStart, trace.connectDone))
+	t.TLS = positive(since(trace.tlsStart, trace.tlsDone))
+	t.TTFB = positive(since(trace.start, trace.firstByte))
+	if trace.end.IsZero() {
+		t.Total = time.Since(trace.start)
+	} else {
+		t.Total = trace.end.Sub(trace.start)
+	}
+	t.Reused = trace.reused
+	t.RemoteAddr = trace.remoteAddr
+}
+
+// Timings returns a snapshot of the timings, nil without TimingInterceptor.
+func (r *response) Timings() *Timings {
+	if r.timings == nil {
+		return nil
+	}
+	return r.timings.get()
+}
+
+// Timing attaches Timings to every response.
+func (c Client) Timing() Client {
+	return c.Interceptor(TimingInterceptor(nil))
+}
+
+// TimingInterceptor attaches Timings to responses, retrieved with
+// Response.Timings. The optional f is called once the body is closed.
+func TimingInterceptor(f func(req Request, t *Timings)) func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+	return func(next func(req Request) (Response, error)) func(req Request) (Response, error) {
+		return func(req Request) (Response, error) {
+			trace, ctx := newRoundTripTrace(req.Context())
+			req.WithContext(ctx)
+			resp, err := next(req)
+			h := &timingsHolder{}
+			h.update(trace)
+			if r, ok := resp.(*response); ok {
+				r.timings = h
+			}
+			if err != nil {
+				if f != nil {
+					f(req, h.get())
+				}
+				return resp, err
+			}
+			OnBodyClose(resp.Response(), func() {
+				trace.finish()
+				h.update(trace)
+				if f != nil {
+					f(req, h.get())
+				}
+			})
+			return resp, nil
+		}
+	}
+}
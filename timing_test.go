@@ -0,0 +1,39 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimingInterceptor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var done *Timings
+	c := NewClient().Interceptor(TimingInterceptor(func(req Request, t *Timings) { done = t }))
+	resp := c.Get(context.TODO(), srv.URL).Do()
+	tm := resp.Timings()
+	if tm.TTFB < 20*time.Millisecond || tm.Connect <= 0 || tm.Reused || len(tm.RemoteAddr) == 0 {
+		t.Fatal("unexpected timings", tm)
+	}
+	if _, err := resp.Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	if done.Total < tm.Total {
+		t.Fatal("total not extended on close", done.Total, tm.Total)
+	}
+
+	tm = c.Get(context.TODO(), srv.URL).Do().Timings()
+	if !tm.Reused || tm.Connect != 0 {
+		t.Fatal("want reused connection", tm)
+	}
+	if NewClient().Get(context.TODO(), srv.URL).Do().Timings() != nil {
+		t.Fatal("timings without interceptor")
+	}
+}
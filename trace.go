@@ -0,0 +1,77 @@
+package xhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// roundTripTrace records the moments of a round trip with httptrace.
+type roundTripTrace struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	end          time.Time
+
+	reused     bool
+	remoteAddr string
+}
+
+func newRoundTripTrace(ctx context.Context) (*roundTripTrace, context.Context) {
+	t := &roundTripTrace{start: time.Now()}
+	set := func(p *time.Time) {
+		t.mu.Lock()
+		if p.IsZero() {
+			*p = time.Now()
+		}
+		t.mu.Unlock()
+	}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { set(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { set(&t.dnsDone) },
+		ConnectStart:      func(network, addr string) { set(&t.connectStart) },
+		ConnectDone:       func(network, addr string, err error) { set(&t.connectDone) },
+		TLSHandshakeStart: func() { set(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { set(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			set(&t.gotConn)
+			t.mu.Lock()
+			t.reused = info.Reused
+			if info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+			t.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { set(&t.firstByte) },
+	})
+	return t, ctx
+}
+
+// finish marks the end of reading the response.
+func (t *roundTripTrace) finish() {
+	t.mu.Lock()
+	if t.end.IsZero() {
+		t.end = time.Now()
+	}
+	t.mu.Unlock()
+}
+
+// since returns b - a, or -1 when one of them did not happen.
+func since(a time.Time, b time.Time) time.Duration {
+	if a.IsZero() || b.IsZero() {
+		return -1
+	}
+	return b.Sub(a)
+}
@@ -0,0 +1,237 @@
+package xhttp
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type EncodeTextMarshaler interface {
+	MarshalText() (text []byte, err error)
+}
+
+// EncodeValues is the counterpart of BindData, encoding the fields of
+// a struct into url.Values. Keys are taken from the given tag, falling
+// back to the field name; a tag of "-" skips the field and the
+// "omitempty" option skips zero values.
+func EncodeValues(v interface{}, tag string) (url.Values, error) {
+	values := url.Values{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("can not encode %v to values", rv.Type())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := encodeValue(values, iter.Key().String(), iter.Value(), tag); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("can not encode %v to values", rv.Type())
+	}
+	if err := encodeStruct(values, "", rv, tag); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func encodeStruct(values url.Values, prefix string, rv reflect.Value, tag string) error {
+	m := OpenReflectMapper(rv.Type(), tag)
+	for _, fi := range m.Fields() {
+		sf := fi.StructField
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		name, omitempty := sf.Name, false
+		if len(tag) > 0 {
+			tv := sf.Tag.Get(tag)
+			if tv == "-" {
+				continue
+			}
+			if p := strings.Index(tv, ","); p >= 0 {
+				omitempty = strings.Contains(tv[p:], ",omitempty")
+				tv = tv[:p]
+			}
+			if len(tv) > 0 {
+				name = tv
+			} else if sf.Anonymous {
+				// fields of an embedded struct are listed on their own
+				continue
+			}
+		} else if sf.Anonymous {
+			continue
+		}
+		fv, ok := fieldByIndexNoAlloc(rv, fi.Index)
+		if !ok {
+			continue
+		}
+		if omitempty && isEmptyValue(fv) {
+			continue
+		}
+		if err := encodeValue(values, prefix+name, fv, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeValue adds rv under key. Slices repeat the key, while nested
+// structs, maps and slices of them are flattened into dotted keys
+// such as "address.city" or "items.0.name".
+func encodeValue(values url.Values, key string, rv reflect.Value, tag string) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		if rv.CanInterface() {
+			if tm, ok := rv.Interface().(EncodeTextMarshaler); ok {
+				return encodeText(values, key, tm)
+			}
+		}
+		rv = rv.Elem()
+	}
+	if rv.CanInterface() {
+		if tm, ok := rv.Interface().(EncodeTextMarshaler); ok {
+			return encodeText(values, key, tm)
+		}
+		if rv.CanAddr() {
+			if tm, ok := rv.Addr().Interface().(EncodeTextMarshaler); ok {
+				return encodeText(values, key, tm)
+			}
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		return encodeStruct(values, key+".", rv, tag)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("can not encode %v to values", rv.Type())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := encodeValue(values, key+"."+iter.Key().String(), iter.Value(), tag); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			values.Add(key, string(bytesOf(rv)))
+			return nil
+		}
+		nested := isNestedType(rv.Type().Elem())
+		for i := 0; i < rv.Len(); i++ {
+			k := key
+			if nested {
+				k = key + "." + strconv.Itoa(i)
+			}
+			if err := encodeValue(values, k, rv.Index(i), tag); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s, err := formatValue(rv)
+	if err != nil {
+		return err
+	}
+	values.Add(key, s)
+	return nil
+}
+
+var encodeTextMarshalerType = reflect.TypeOf((*EncodeTextMarshaler)(nil)).Elem()
+
+func isNestedType(t reflect.Type) bool {
+	if t.Implements(encodeTextMarshalerType) || reflect.PtrTo(t).Implements(encodeTextMarshalerType) {
+		return false
+	}
+	t = Deref(t)
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+func encodeText(values url.Values, key string, tm EncodeTextMarshaler) error {
+	b, err := tm.MarshalText()
+	if err != nil {
+		return err
+	}
+	values.Add(key, string(b))
+	return nil
+}
+
+func formatValue(rv reflect.Value) (string, error) {
+	switch rv.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("can not encode field of kind %v", rv.Kind())
+}
+
+func bytesOf(rv reflect.Value) []byte {
+	if rv.Kind() == reflect.Slice {
+		return rv.Bytes()
+	}
+	b := make([]byte, rv.Len())
+	reflect.Copy(reflect.ValueOf(b), rv)
+	return b
+}
+
+// fieldByIndexNoAlloc is like FieldByIndex but reports false on a nil
+// embedded pointer instead of allocating it.
+func fieldByIndexNoAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return zeroValue, false
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
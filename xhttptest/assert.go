@@ -0,0 +1,178 @@
+package xhttptest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-comm/xhttp"
+)
+
+var update = flag.Bool("xhttptest.update", false, "update golden files")
+
+// HandlerTester drives a handler in-process through xhttp.Client.
+//
+//	ht := xhttptest.NewHandlerTester(t, router)
+//	ht.Do(ht.Client().Get(ctx, "/users/1")).Status(200).JSONPath("name", "a")
+type HandlerTester struct {
+	t      TestingT
+	client xhttp.Client
+}
+
+func NewHandlerTester(t TestingT, h http.Handler) *HandlerTester {
+	return &HandlerTester{t: t, client: xhttp.NewClientForHandler(h).BaseURL("http://example.com")}
+}
+
+// Client returns the client whose requests are served by the handler.
+func (ht *HandlerTester) Client() xhttp.Client {
+	return ht.client
+}
+
+func (ht *HandlerTester) Request(method string, path string, body interface{}) xhttp.Request {
+	return ht.client.Request(context.Background(), method, path, body)
+}
+
+// Do sends req and reads the whole response for assertions.
+func (ht *HandlerTester) Do(req xhttp.Request) *Assertions {
+	ht.t.Helper()
+	a := &Assertions{t: ht.t}
+	resp := req.Do()
+	if err := resp.Error(); err != nil {
+		ht.t.Errorf("xhttptest: %v", err)
+		a.failed = true
+		return a
+	}
+	a.Response = resp.Response()
+	body, err := ioutil.ReadAll(a.Response.Body)
+	a.Response.Body.Close()
+	if err != nil {
+		ht.t.Errorf("xhttptest: read body: %v", err)
+	}
+	a.Body = body
+	return a
+}
+
+// Assertions report failures with Errorf, so all of them are checked.
+type Assertions struct {
+	Response *http.Response
+	Body     []byte
+
+	t      TestingT
+	failed bool
+}
+
+func (a *Assertions) Status(code int) *Assertions {
+	a.t.Helper()
+	if !a.failed && a.Response.StatusCode != code {
+		a.t.Errorf("xhttptest: status %d, want %d, body %s", a.Response.StatusCode, code, a.Body)
+	}
+	return a
+}
+
+func (a *Assertions) Header(k string, v string) *Assertions {
+	a.t.Helper()
+	if !a.failed && a.Response.Header.Get(k) != v {
+		a.t.Errorf("xhttptest: header %s %q, want %q", k, a.Response.Header.Get(k), v)
+	}
+	return a
+}
+
+func (a *Assertions) BodyContains(s string) *Assertions {
+	a.t.Helper()
+	if !a.failed && !bytes.Contains(a.Body, []byte(s)) {
+		a.t.Errorf("xhttptest: body %s does not contain %q", a.Body, s)
+	}
+	return a
+}
+
+// JSONPath compares the value at the dotted path of the JSON body, such as
+// "items.0.name", with want after both are converted to plain JSON values.
+func (a *Assertions) JSONPath(path string, want interface{}) *Assertions {
+	a.t.Helper()
+	if a.failed {
+		return a
+	}
+	var doc interface{}
+	if err := json.Unmarshal(a.Body, &doc); err != nil {
+		a.t.Errorf("xhttptest: body is not JSON: %v", err)
+		return a
+	}
+	got, ok := lookupJSONPath(doc, path)
+	if !ok {
+		a.t.Errorf("xhttptest: JSON path %q not found in %s", path, a.Body)
+		return a
+	}
+	b, err := json.Marshal(want)
+	if err != nil {
+		a.t.Errorf("xhttptest: %v", err)
+		return a
+	}
+	var w interface{}
+	json.Unmarshal(b, &w)
+	if !reflect.DeepEqual(got, w) {
+		gb, _ := json.Marshal(got)
+		a.t.Errorf("xhttptest: JSON path %q is %s, want %s", path, gb, b)
+	}
+	return a
+}
+
+// Golden compares the body with testdata/<name>.golden, the file is
+// written instead when the test runs with -xhttptest.update.
+func (a *Assertions) Golden(name string) *Assertions {
+	a.t.Helper()
+	if a.failed {
+		return a
+	}
+	file := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			a.t.Errorf("xhttptest: %v", err)
+			return a
+		}
+		if err := ioutil.WriteFile(file, a.Body, 0644); err != nil {
+			a.t.Errorf("xhttptest: %v", err)
+		}
+		return a
+	}
+	want, err := ioutil.ReadFile(file)
+	if err != nil {
+		a.t.Errorf("xhttptest: %v, run with -xhttptest.update to create it", err)
+		return a
+	}
+	if !bytes.Equal(a.Body, want) {
+		a.t.Errorf("xhttptest: body differs from %s\ngot:\n%s\nwant:\n%s", file, a.Body, want)
+	}
+	return a
+}
+
+func lookupJSONPath(v interface{}, path string) (interface{}, bool) {
+	if len(path) == 0 {
+		return v, true
+	}
+	for _, k := range strings.Split(path, ".") {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = x[k]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, false
+			}
+			v = x[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,33 @@
+package xhttptest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-comm/xhttp"
+)
+
+func TestHandlerTester(t *testing.T) {
+	router := xhttp.NewRouter()
+	router.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"id":1,"name":"a"}],"total":1}` + "\n"))
+	})
+
+	ht := NewHandlerTester(t, router)
+	ht.Do(ht.Request("GET", "/users", nil).Query("page", "1")).
+		Status(200).
+		Header("Content-Type", "application/json").
+		JSONPath("total", 1).
+		JSONPath("items.0", map[string]interface{}{"id": 1, "name": "a"}).
+		BodyContains(`"name":"a"`).
+		Golden("users")
+
+	ft := &fakeT{}
+	ht = NewHandlerTester(ft, router)
+	ht.Do(ht.Request("GET", "/users", nil)).Status(201).JSONPath("items.0.name", "b").JSONPath("missing", 1)
+	if len(ft.errors) != 3 || !strings.Contains(ft.errors[1], `"items.0.name" is "a", want "b"`) {
+		t.Fatal("unexpected errors", ft.errors)
+	}
+}
@@ -0,0 +1,241 @@
+// Package xhttptest provides a mock server and assertions for tests of
+// code built on xhttp.
+package xhttptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	"github.com/go-comm/xhttp"
+)
+
+// TestingT is the subset of testing.TB used by the package.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Cleanup(f func())
+}
+
+type RecordedRequest struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// Server is a httptest server answering requests with declared expectations.
+// Unmet expectations are reported when the test finishes.
+//
+//	mock := xhttptest.NewServer(t)
+//	mock.On("GET", "/users/1").Reply(200).JSON(u).Times(2)
+type Server struct {
+	*httptest.Server
+	Router *xhttp.Router
+
+	t TestingT
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	requests     []*RecordedRequest
+}
+
+func NewServer(t TestingT) *Server {
+	s := &Server{t: t, Router: xhttp.NewRouter()}
+	s.Router.HandleFunc("/", s.serve)
+	s.Server = httptest.NewServer(s.Router)
+	t.Cleanup(func() {
+		s.Close()
+		s.Verify()
+	})
+	return s
+}
+
+// Client returns a client with the server url as base url.
+func (s *Server) Client() xhttp.Client {
+	return xhttp.NewClient().BaseURL(s.URL)
+}
+
+// On declares an expectation for method and path, method "*" matches any method.
+func (s *Server) On(method string, path string) *Expectation {
+	e := &Expectation{method: method, path: path, code: http.StatusOK, header: make(http.Header)}
+	s.mu.Lock()
+	s.expectations = append(s.expectations, e)
+	s.mu.Unlock()
+	return e
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []*RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*RecordedRequest(nil), s.requests...)
+}
+
+// Verify reports expectations that were not called as often as declared.
+func (s *Server) Verify() {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.expectations {
+		if e.calls < e.min() {
+			s.t.Errorf("xhttptest: %s %s called %d times, want %d", e.method, e.path, e.calls, e.min())
+		}
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	u := *r.URL
+	s.mu.Lock()
+	s.requests = append(s.requests, &RecordedRequest{Method: r.Method, URL: &u, Header: r.Header.Clone(), Body: body})
+	var matched *Expectation
+	for _, e := range s.expectations {
+		if e.match(r, body) {
+			e.calls++
+			matched = e
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if matched == nil {
+		s.t.Errorf("xhttptest: unexpected request %s %s", r.Method, r.URL.RequestURI())
+		http.Error(w, "xhttptest: no expectation for "+r.Method+" "+r.URL.Path, http.StatusNotImplemented)
+		return
+	}
+	matched.reply(w, r)
+}
+
+type Expectation struct {
+	method  string
+	path    string
+	query   url.Values
+	headers http.Header
+	body    []byte
+
+	code    int
+	header  http.Header
+	resBody []byte
+	handler http.HandlerFunc
+	err     error
+
+	times int
+	calls int
+}
+
+// WithQuery requires the query parameter k to be v.
+func (e *Expectation) WithQuery(k string, v string) *Expectation {
+	if e.query == nil {
+		e.query = url.Values{}
+	}
+	e.query.Add(k, v)
+	return e
+}
+
+// WithHeader requires the request header k to be v.
+func (e *Expectation) WithHeader(k string, v string) *Expectation {
+	if e.headers == nil {
+		e.headers = make(http.Header)
+	}
+	e.headers.Add(k, v)
+	return e
+}
+
+// WithBody requires the request body to be b.
+func (e *Expectation) WithBody(b string) *Expectation {
+	e.body = []byte(b)
+	return e
+}
+
+func (e *Expectation) Reply(code int) *Expectation {
+	e.code = code
+	return e
+}
+
+func (e *Expectation) Header(k string, v string) *Expectation {
+	e.header.Add(k, v)
+	return e
+}
+
+func (e *Expectation) Body(b string) *Expectation {
+	e.resBody = []byte(b)
+	return e
+}
+
+// JSON replies v encoded as JSON.
+func (e *Expectation) JSON(v interface{}) *Expectation {
+	e.resBody, e.err = json.Marshal(v)
+	e.header.Set("Content-Type", "application/json")
+	return e
+}
+
+// Handler replies with h, overriding the declared response.
+func (e *Expectation) Handler(h http.HandlerFunc) *Expectation {
+	e.handler = h
+	return e
+}
+
+// Times makes the expectation match exactly n requests. By default it
+// matches any number of requests and must be called at least once.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+func (e *Expectation) min() int {
+	if e.times > 0 {
+		return e.times
+	}
+	return 1
+}
+
+func (e *Expectation) match(r *http.Request, body []byte) bool {
+	if e.times > 0 && e.calls >= e.times {
+		return false
+	}
+	if e.method != "*" && e.method != r.Method {
+		return false
+	}
+	if e.path != r.URL.Path {
+		return false
+	}
+	q := r.URL.Query()
+	for k, vs := range e.query {
+		if fmt.Sprint(q[k]) != fmt.Sprint(vs) {
+			return false
+		}
+	}
+	for k, vs := range e.headers {
+		if fmt.Sprint(r.Header[k]) != fmt.Sprint(vs) {
+			return false
+		}
+	}
+	return e.body == nil || bytes.Equal(e.body, body)
+}
+
+func (e *Expectation) reply(w http.ResponseWriter, r *http.Request) {
+	if e.handler != nil {
+		e.handler(w, r)
+		return
+	}
+	if e.err != nil {
+		http.Error(w, e.err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for k, vs := range e.header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(e.code)
+	w.Write(e.resBody)
+}
@@ -0,0 +1,75 @@
+package xhttptest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type fakeT struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeT) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestServer(t *testing.T) {
+	mock := NewServer(t)
+	mock.On("GET", "/users/1").Reply(200).JSON(user{ID: 1, Name: "a"}).Times(2)
+	mock.On("POST", "/users").WithHeader("X-Token", "t").WithBody(`{"name":"b"}`).Reply(201)
+
+	c := mock.Client()
+	for i := 0; i < 2; i++ {
+		var u user
+		if err := c.Get(context.TODO(), "/users/1").Do().JSON(&u); err != nil || u.Name != "a" {
+			t.Fatal(u, err)
+		}
+	}
+	resp := c.Post(context.TODO(), "/users", `{"name":"b"}`).SetHeader("X-Token", "t").Do()
+	if err := resp.Error(); err != nil || resp.Response().StatusCode != 201 {
+		t.Fatal("unexpected response", err)
+	}
+	resp.Response().Body.Close()
+
+	reqs := mock.Requests()
+	if len(reqs) != 3 || reqs[2].Method != "POST" || string(reqs[2].Body) != `{"name":"b"}` {
+		t.Fatal("unexpected recorded requests", reqs)
+	}
+}
+
+func TestServerUnmet(t *testing.T) {
+	ft := &fakeT{}
+	mock := NewServer(ft)
+	mock.On("GET", "/a").Reply(204).Once()
+	mock.On("GET", "/b")
+
+	c := mock.Client()
+	c.Get(context.TODO(), "/a").Do().Bytes()
+	c.Get(context.TODO(), "/a").Do().Bytes()
+	ft.finish()
+
+	if len(ft.errors) != 2 || !strings.Contains(ft.errors[0], "unexpected request GET /a") ||
+		!strings.Contains(ft.errors[1], "GET /b called 0 times") {
+		t.Fatal("unexpected errors", ft.errors)
+	}
+}